@@ -0,0 +1,132 @@
+package trevor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+func (s *server) AdminHandler() http.Handler {
+	router := http.NewServeMux()
+	router.HandleFunc("/", infoHandler(s))
+	router.HandleFunc("/plugins", infoFieldHandler(s, "plugins", func(info EngineInfo) interface{} { return info.Plugins }))
+	router.HandleFunc("/plugins/", pluginStateHandler("/plugins/", s))
+	router.HandleFunc("/services", infoFieldHandler(s, "services", func(info EngineInfo) interface{} { return info.Services }))
+	router.HandleFunc("/pokes", infoFieldHandler(s, "pokes", func(info EngineInfo) interface{} { return info.Pokes }))
+	router.HandleFunc("/config", infoFieldHandler(s, "config", func(info EngineInfo) interface{} { return configInfo(s.config.withDefaults(), info) }))
+	if s.config.Reload != nil {
+		router.HandleFunc("/reload", reloadHandler(s))
+	}
+
+	return router
+}
+
+// infoHandler shows all the information of the engine and the config the server was created with.
+func infoHandler(s *server) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		info := s.engine.Info()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"error":      false,
+			"plugins":    info.Plugins,
+			"services":   info.Services,
+			"middleware": info.Middleware,
+			"pokes":      info.Pokes,
+			"config":     configInfo(s.config.withDefaults(), info),
+		})
+	}
+}
+
+// infoFieldHandler shows a single field of the information of the engine.
+func infoFieldHandler(s *server, key string, field func(EngineInfo) interface{}) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.NotFound(w, r)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"error": false,
+			key:     field(s.engine.Info()),
+		})
+	}
+}
+
+// pluginStateHandler enables or disables a plugin with a request to <prefix><name>/enable
+// or <prefix><name>/disable.
+func pluginStateHandler(prefix string, s *server) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		i := strings.LastIndex(path, "/")
+		if r.Method != "POST" || i <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		name, action := path[:i], path[i+1:]
+		if action != "enable" && action != "disable" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var err error
+		if action == "enable" {
+			err = s.engine.EnablePlugin(name)
+		} else {
+			err = s.engine.DisablePlugin(name)
+		}
+
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"error": false})
+	}
+}
+
+// reloadHandler replaces the plugins and services of the engine with the ones returned by Config.Reload.
+func reloadHandler(s *server) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.NotFound(w, r)
+			return
+		}
+
+		plugins, services, err := s.config.Reload()
+		if err == nil {
+			err = s.engine.Reload(plugins, services)
+		}
+
+		if err != nil {
+			var configErr *ConfigError
+			if errors.As(err, &configErr) {
+				writeError(w, http.StatusUnprocessableEntity, err)
+			} else {
+				writeError(w, http.StatusInternalServerError, err)
+			}
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"error": false})
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]interface{}{
+		"error":   true,
+		"message": err.Error(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	resp, _ := json.Marshal(response)
+	w.Write(resp)
+}
@@ -0,0 +1,135 @@
+package trevor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func adminRequest(t *testing.T, handler http.Handler, method, path string) (int, map[string]interface{}) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil && rec.Code == http.StatusOK {
+		t.Fatalf("invalid JSON response: %s", rec.Body.String())
+	}
+
+	return rec.Code, body
+}
+
+func TestAdminHandler(t *testing.T) {
+	server := NewServer(Config{
+		Plugins:  []Plugin{&barPlugin{}, &pokablePlugin{indexPlugin: indexPlugin{name: "pokable"}}},
+		Services: dummyServices(),
+		Middleware: []Middleware{
+			func(req *Request, _ func(string) Service, next func() (string, interface{}, error)) (string, interface{}, error) {
+				return next()
+			},
+		},
+		KeyPerm: "/secret/key.pem",
+		Timeout: time.Second,
+	})
+	server.GetEngine().SchedulePokes()
+	defer server.GetEngine().Shutdown(context.Background())
+	time.Sleep(20 * time.Millisecond)
+
+	status, body := adminRequest(t, server.AdminHandler(), "GET", "/")
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+
+	plugins := body["plugins"].([]interface{})
+	bar := plugins[0].(map[string]interface{})
+	if bar["name"] != "bar" || !reflect.DeepEqual(bar["services"], []interface{}{"bar"}) {
+		t.Errorf("expected bar plugin with bar service injected, got %v", bar)
+	}
+
+	if services := body["services"].([]interface{}); len(services) != 2 {
+		t.Errorf("expected 2 services, got %v", services)
+	}
+
+	if body["middleware"] != float64(1) {
+		t.Errorf("expected 1 middleware, got %v", body["middleware"])
+	}
+
+	pokes := body["pokes"].([]interface{})
+	if len(pokes) != 2 {
+		t.Fatalf("expected 2 poke schedules, got %v", pokes)
+	}
+
+	poke := pokes[0].(map[string]interface{})
+	if poke["component"] != "plugin pokable" || poke["last_run"] == "0001-01-01T00:00:00Z" {
+		t.Errorf("expected pokable plugin to have been poked, got %v", poke)
+	}
+
+	if poke["every"] != "5ms" {
+		t.Errorf("expected poke every to be a duration string, got %v", poke["every"])
+	}
+
+	config := body["config"].(map[string]interface{})
+	expected := map[string]interface{}{
+		"KeyPerm":  redacted,
+		"Endpoint": "process",
+		"Timeout":  "1s",
+		"Plugins":  []interface{}{"bar", "pokable"},
+	}
+	for key, value := range expected {
+		if !reflect.DeepEqual(config[key], value) {
+			t.Errorf("expected config %s to be %v, got %v", key, value, config[key])
+		}
+	}
+}
+
+func TestAdminConfigAfterReload(t *testing.T) {
+	server := NewServer(Config{
+		Plugins: dummyPlugins(),
+		Reload: func() ([]Plugin, []Service, error) {
+			return []Plugin{&indexPlugin{name: "reloaded"}}, nil, nil
+		},
+	})
+	handler := server.AdminHandler()
+
+	if status, _ := adminRequest(t, handler, "POST", "/reload"); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+
+	_, body := adminRequest(t, handler, "GET", "/config")
+	config := body["config"].(map[string]interface{})
+	if !reflect.DeepEqual(config["Plugins"], []interface{}{"reloaded"}) {
+		t.Errorf("expected config to show the reloaded plugins, got %v", config["Plugins"])
+	}
+}
+
+func TestAdminHandlerRoutes(t *testing.T) {
+	handler := NewServer(Config{Plugins: dummyPlugins()}).AdminHandler()
+
+	cases := []struct {
+		method string
+		path   string
+		key    string
+		status int
+	}{
+		{"GET", "/plugins", "plugins", http.StatusOK},
+		{"GET", "/services", "services", http.StatusOK},
+		{"GET", "/pokes", "pokes", http.StatusOK},
+		{"GET", "/config", "config", http.StatusOK},
+		{"POST", "/config", "", http.StatusNotFound},
+		{"GET", "/unknown", "", http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		status, body := adminRequest(t, handler, c.method, c.path)
+		if status != c.status {
+			t.Errorf("%s %s: expected status %d, got %d", c.method, c.path, c.status, status)
+		}
+
+		if _, ok := body[c.key]; c.key != "" && !ok {
+			t.Errorf("%s %s: expected response to contain %s", c.method, c.path, c.key)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package trevor
 
-import "sort"
+import (
+	"context"
+	"sync"
+	"time"
+)
 
 type analysisResult struct {
 	score        float64
@@ -14,62 +18,202 @@ func newAnalysisResult(score float64, isExactMatch bool, precedence int, name st
 	return analysisResult{score: score, isExactMatch: isExactMatch, precedence: precedence, name: name, metadata: metadata}
 }
 
-type byMatch []analysisResult
+// Candidate is a plugin that could have processed a request.
+type Candidate struct {
+	// Plugin is the name of the plugin.
+	Plugin string `json:"type"`
 
-func (b byMatch) Len() int {
-	return len(b)
+	// Score is the score the plugin returned for the request.
+	Score float64 `json:"score"`
+
+	// ExactMatch is true if the plugin returned an exact match for the request.
+	ExactMatch bool `json:"exact_match"`
+
+	// Precedence is the precedence of the plugin.
+	Precedence int `json:"-"`
+
+	// index is the position of the candidate before being ranked.
+	index int
 }
 
-func (b byMatch) Swap(i, j int) {
-	b[i], b[j] = b[j], b[i]
+func (r analysisResult) candidate() Candidate {
+	return Candidate{
+		Plugin:     r.name,
+		Score:      r.score,
+		ExactMatch: r.isExactMatch,
+		Precedence: r.precedence,
+	}
 }
 
-func (b byMatch) Less(i, j int) bool {
-	return b[i].isExactMatch && !b[j].isExactMatch
+// sortAnalysisResults sorts the results using the DefaultRanker.
+func sortAnalysisResults(results []analysisResult) {
+	rankResults(results, DefaultRanker)
 }
 
-type byScore []analysisResult
+// analysisOptions are the options used to get the analysis results of the plugins.
+type analysisOptions struct {
+	// timeout is the maximum time a plugin can spend on its analysis.
+	timeout time.Duration
 
-func (b byScore) Len() int {
-	return len(b)
-}
+	// concurrency is the maximum number of plugins analysing at the same time.
+	// Zero or less means all of them.
+	concurrency int
 
-func (b byScore) Swap(i, j int) {
-	b[i], b[j] = b[j], b[i]
+	// shortCircuit stops the analysis as soon as there is an exact match and
+	// no plugin with a higher precedence is still pending.
+	shortCircuit bool
 }
 
-func (b byScore) Less(i, j int) bool {
-	return b[i].score > b[j].score
+type analysisOutput struct {
+	index  int
+	result analysisResult
+	err    error
 }
 
-type byPrecedence []analysisResult
+// getResults analyzes the request with all plugins using a bounded pool of
+// workers. The results are returned in the same order as the plugins no
+// matter the order in which they were completed, leaving out the plugins
+// that failed to analyze the request. Plugins are expected to be sorted
+// by precedence, as they are analyzed in that order.
+func getResults(ctx context.Context, plugins []Plugin, req *Request, opts analysisOptions) []analysisResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := opts.concurrency
+	if workers <= 0 || workers > len(plugins) {
+		workers = len(plugins)
+	}
 
-func (b byPrecedence) Len() int {
-	return len(b)
-}
+	var (
+		jobs    = make(chan int)
+		outputs = make(chan analysisOutput, len(plugins))
+		wg      sync.WaitGroup
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				plugin := plugins[i]
+				score, metadata, err := analyzePlugin(ctx, plugin, req, opts.timeout)
+				if err != nil {
+					outputs <- analysisOutput{index: i, err: err}
+					continue
+				}
+
+				outputs <- analysisOutput{
+					index:  i,
+					result: newAnalysisResult(score.Score(), score.IsExactMatch(), plugin.Precedence(), plugin.Name(), metadata),
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range plugins {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(outputs)
+	}()
+
+	var (
+		analyzed = make([]*analysisResult, len(plugins))
+		done     = make([]bool, len(plugins))
+	)
+
+	for output := range outputs {
+		done[output.index] = true
+		if output.err == nil {
+			result := output.result
+			analyzed[output.index] = &result
+		}
+
+		if opts.shortCircuit && canShortCircuit(plugins, analyzed, done) {
+			cancel()
+		}
+	}
+
+	results := make([]analysisResult, 0, len(plugins))
+	for _, result := range analyzed {
+		if result != nil {
+			results = append(results, *result)
+		}
+	}
 
-func (b byPrecedence) Swap(i, j int) {
-	b[i], b[j] = b[j], b[i]
+	return results
 }
 
-func (b byPrecedence) Less(i, j int) bool {
-	return b[i].precedence > b[j].precedence
+// canShortCircuit reports whether there is an exact match whose plugin does
+// not have any plugin with a higher precedence still pending.
+func canShortCircuit(plugins []Plugin, analyzed []*analysisResult, done []bool) bool {
+	for _, result := range analyzed {
+		if result == nil || !result.isExactMatch {
+			continue
+		}
+
+		pending := false
+		for j := range plugins {
+			if !done[j] && plugins[j].Precedence() > result.precedence {
+				pending = true
+				break
+			}
+		}
+
+		if !pending {
+			return true
+		}
+	}
+
+	return false
 }
 
-func sortAnalysisResults(results []analysisResult) {
-	sort.Sort(byPrecedence(results))
-	sort.Sort(byScore(results))
-	sort.Sort(byMatch(results))
+// filterByScore returns the results that are exact matches or have at least
+// the given score. A zero score means no filter.
+func filterByScore(results []analysisResult, minScore float64) []analysisResult {
+	if minScore == 0 {
+		return results
+	}
+
+	filtered := make([]analysisResult, 0, len(results))
+	for _, result := range results {
+		if result.isExactMatch || result.score >= minScore {
+			filtered = append(filtered, result)
+		}
+	}
+
+	return filtered
 }
 
-func getResults(plugins []Plugin, req *Request) []analysisResult {
-	results := make([]analysisResult, len(plugins))
-	for i, plugin := range plugins {
-		score, metadata := plugin.Analyze(req)
-		results[i] = newAnalysisResult(score.Score(), score.IsExactMatch(), plugin.Precedence(), plugin.Name(), metadata)
+// alternatives returns up to n candidates from the given sorted results, leaving out the
+// chosen one and the ones that failed to process the request.
+func alternatives(results []analysisResult, chosen string, failed []PluginError, n int) []Candidate {
+	excluded := map[string]bool{chosen: true}
+	for _, f := range failed {
+		excluded[f.Plugin] = true
 	}
 
-	return results
+	candidates := make([]Candidate, 0, n)
+	for _, result := range results {
+		if len(candidates) >= n {
+			break
+		}
+
+		if !excluded[result.name] {
+			candidates = append(candidates, result.candidate())
+		}
+	}
+
+	return candidates
 }
 
 func getBestResult(results []analysisResult) analysisResult {
@@ -1,7 +1,11 @@
 package trevor
 
 import (
+	"context"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_sortAnalysisResults(t *testing.T) {
@@ -43,3 +47,165 @@ func getTestCases() ([]analysisResult, []analysisResult) {
 
 	return input, expected
 }
+
+type indexPlugin struct {
+	name       string
+	score      float64
+	exactMatch bool
+	precedence int
+	delay      time.Duration
+	running    *int32
+	maxRunning *int32
+}
+
+func (p *indexPlugin) Analyze(req *Request) (Score, interface{}) {
+	if p.running != nil {
+		n := atomic.AddInt32(p.running, 1)
+		defer atomic.AddInt32(p.running, -1)
+		for {
+			max := atomic.LoadInt32(p.maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(p.maxRunning, max, n) {
+				break
+			}
+		}
+	}
+
+	time.Sleep(p.delay)
+	return NewScore(p.score, p.exactMatch), p.name
+}
+
+func (p *indexPlugin) Process(req *Request, metadata interface{}) (interface{}, error) {
+	return metadata, nil
+}
+
+func (p *indexPlugin) Name() string {
+	return p.name
+}
+
+func (p *indexPlugin) Precedence() int {
+	return p.precedence
+}
+
+func indexPlugins(n int, delay time.Duration) []Plugin {
+	plugins := make([]Plugin, n)
+	for i := range plugins {
+		plugins[i] = &indexPlugin{
+			name:       fmt.Sprintf("index_%d", i),
+			score:      float64(i % 3),
+			precedence: 1,
+			delay:      delay,
+		}
+	}
+
+	return plugins
+}
+
+func Test_getResults(t *testing.T) {
+	plugins := indexPlugins(12, time.Millisecond)
+	results := getResults(context.Background(), plugins, NewRequest("foo", nil), analysisOptions{})
+
+	if len(results) != len(plugins) {
+		t.Fatalf("expected %d results, %d found", len(plugins), len(results))
+	}
+
+	for i, result := range results {
+		if result.name != plugins[i].Name() {
+			t.Errorf("expected %s to be at position %d, %s found", plugins[i].Name(), i, result.name)
+		}
+	}
+
+	if best := getBestResult(results); best.name != "index_2" {
+		t.Errorf("expected index_2 to be best result, %s found", best.name)
+	}
+}
+
+func Test_getResultsConcurrency(t *testing.T) {
+	var running, maxRunning int32
+	plugins := indexPlugins(12, 2*time.Millisecond)
+	for _, p := range plugins {
+		p.(*indexPlugin).running = &running
+		p.(*indexPlugin).maxRunning = &maxRunning
+	}
+
+	getResults(context.Background(), plugins, NewRequest("foo", nil), analysisOptions{concurrency: 3})
+
+	if maxRunning != 3 {
+		t.Errorf("expected at most 3 plugins analysing at the same time, %d found", maxRunning)
+	}
+}
+
+func Test_getResultsTimeout(t *testing.T) {
+	plugins := []Plugin{
+		&indexPlugin{name: "fast", score: 1, precedence: 1},
+		&indexPlugin{name: "slow", score: 5, precedence: 1, delay: 50 * time.Millisecond},
+	}
+
+	results := getResults(context.Background(), plugins, NewRequest("foo", nil), analysisOptions{timeout: 10 * time.Millisecond})
+	if len(results) != 1 || results[0].name != "fast" {
+		t.Errorf("expected only the fast plugin to have a result, got %v", results)
+	}
+}
+
+func Test_getResultsShortCircuit(t *testing.T) {
+	plugins := []Plugin{
+		&indexPlugin{name: "exact", score: 1, exactMatch: true, precedence: 2},
+		&indexPlugin{name: "slow", score: 5, precedence: 1, delay: 200 * time.Millisecond},
+	}
+	SortPlugins(plugins)
+
+	start := time.Now()
+	results := getResults(context.Background(), plugins, NewRequest("foo", nil), analysisOptions{shortCircuit: true})
+	if time.Since(start) > 100*time.Millisecond {
+		t.Errorf("expected analysis to stop after the exact match")
+	}
+
+	if len(results) != 1 || results[0].name != "exact" {
+		t.Errorf("expected only the exact plugin to have a result, got %v", results)
+	}
+}
+
+func Test_getResultsShortCircuitWaitsForPrecedence(t *testing.T) {
+	plugins := []Plugin{
+		&indexPlugin{name: "exact", score: 1, exactMatch: true, precedence: 1},
+		&indexPlugin{name: "slow", score: 5, exactMatch: true, precedence: 2, delay: 20 * time.Millisecond},
+		&indexPlugin{name: "slower", score: 5, precedence: 0, delay: 200 * time.Millisecond},
+	}
+	SortPlugins(plugins)
+
+	start := time.Now()
+	results := getResults(context.Background(), plugins, NewRequest("foo", nil), analysisOptions{shortCircuit: true})
+	if time.Since(start) > 100*time.Millisecond {
+		t.Errorf("expected analysis to stop after the higher precedence plugin finished")
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %v", results)
+	}
+
+	if best := getBestResult(results); best.name != "slow" {
+		t.Errorf("expected slow to be best result, %s found", best.name)
+	}
+}
+
+func benchmarkGetResults(b *testing.B, concurrency int) {
+	plugins := indexPlugins(12, time.Millisecond)
+	req := NewRequest("foo", nil)
+	opts := analysisOptions{concurrency: concurrency}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		getBestResult(getResults(context.Background(), plugins, req, opts))
+	}
+}
+
+func BenchmarkGetResultsSerial(b *testing.B) {
+	benchmarkGetResults(b, 1)
+}
+
+func BenchmarkGetResultsParallel4(b *testing.B) {
+	benchmarkGetResults(b, 4)
+}
+
+func BenchmarkGetResultsParallel(b *testing.B) {
+	benchmarkGetResults(b, 0)
+}
@@ -0,0 +1,163 @@
+package trevor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Attributes are the fields of the JSON object sent to the endpoint besides the
+// text and the plugins chosen by the client, e.g. the locale, timezone or coordinates
+// of the user. Values are kept as raw JSON so they can be decoded into any type.
+type Attributes map[string]json.RawMessage
+
+// Has reports whether the attribute with the given name was sent.
+func (a Attributes) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+// Decode decodes the attribute with the given name into v. It returns an
+// *AttributeError if the attribute was not sent or can't be decoded into v.
+func (a Attributes) Decode(name string, v interface{}) error {
+	raw, ok := a[name]
+	if !ok {
+		return &AttributeError{Name: name, Message: "not found"}
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &AttributeError{Name: name, Message: err.Error()}
+	}
+
+	return nil
+}
+
+// Attribute returns the attribute of the request with the given name decoded as T, e.g:
+//
+//	coords, err := trevor.Attribute[Coordinates](req, "coordinates")
+func Attribute[T any](req *Request, name string) (T, error) {
+	var v T
+	err := req.Attributes.Decode(name, &v)
+	return v, err
+}
+
+// AttributeError is the error of an attribute that is missing or has an invalid value.
+type AttributeError struct {
+	// Name is the name of the attribute.
+	Name string
+
+	// Message describes the problem.
+	Message string
+}
+
+func (e *AttributeError) Error() string {
+	return fmt.Sprintf("attribute %s: %s", e.Name, e.Message)
+}
+
+// AttributeType is the JSON type of an attribute.
+type AttributeType string
+
+const (
+	// AnyAttribute accepts any JSON value.
+	AnyAttribute AttributeType = ""
+	// StringAttribute accepts JSON strings.
+	StringAttribute AttributeType = "string"
+	// NumberAttribute accepts JSON numbers.
+	NumberAttribute AttributeType = "number"
+	// BoolAttribute accepts JSON booleans.
+	BoolAttribute AttributeType = "boolean"
+	// ObjectAttribute accepts JSON objects.
+	ObjectAttribute AttributeType = "object"
+	// ArrayAttribute accepts JSON arrays.
+	ArrayAttribute AttributeType = "array"
+)
+
+// AttributeSchema describes the values accepted for an attribute.
+type AttributeSchema struct {
+	// Type is the JSON type of the attribute.
+	Type AttributeType
+
+	// Required makes the requests without the attribute invalid.
+	Required bool
+
+	// Validate, if not nil, checks the value of the attribute after its type.
+	Validate func(json.RawMessage) error
+}
+
+// Schema describes the attributes accepted by the server.
+type Schema struct {
+	// Attributes are the schemas of the attributes by name.
+	Attributes map[string]AttributeSchema
+
+	// AllowUnknown allows attributes that are not in Attributes.
+	AllowUnknown bool
+}
+
+// Validate checks the given attributes against the schema and returns an error
+// with an *AttributeError for every problem found, sorted by attribute name.
+func (s *Schema) Validate(attrs Attributes) error {
+	var errs []error
+	for _, name := range sortedKeys(s.Attributes, attrs) {
+		schema, known := s.Attributes[name]
+		raw, sent := attrs[name]
+		switch {
+		case !known:
+			if !s.AllowUnknown {
+				errs = append(errs, &AttributeError{Name: name, Message: "unknown attribute"})
+			}
+		case !sent || jsonType(raw) == "null":
+			if schema.Required {
+				errs = append(errs, &AttributeError{Name: name, Message: "is required"})
+			}
+		case schema.Type != AnyAttribute && jsonType(raw) != schema.Type:
+			errs = append(errs, &AttributeError{Name: name, Message: fmt.Sprintf("must be of type %s", schema.Type)})
+		case schema.Validate != nil:
+			if err := schema.Validate(raw); err != nil {
+				errs = append(errs, &AttributeError{Name: name, Message: err.Error()})
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// jsonType returns the type of the given JSON value.
+func jsonType(raw json.RawMessage) AttributeType {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return AnyAttribute
+	}
+
+	switch raw[0] {
+	case '"':
+		return StringAttribute
+	case '{':
+		return ObjectAttribute
+	case '[':
+		return ArrayAttribute
+	case 't', 'f':
+		return BoolAttribute
+	case 'n':
+		return "null"
+	default:
+		return NumberAttribute
+	}
+}
+
+func sortedKeys(schemas map[string]AttributeSchema, attrs Attributes) []string {
+	keys := make([]string, 0, len(schemas)+len(attrs))
+	for name := range schemas {
+		keys = append(keys, name)
+	}
+
+	for name := range attrs {
+		if _, ok := schemas[name]; !ok {
+			keys = append(keys, name)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
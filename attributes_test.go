@@ -0,0 +1,145 @@
+package trevor
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAttributes(t *testing.T) {
+	req := NewRequest("foo", nil)
+	req.Attributes = Attributes{
+		"locale":      json.RawMessage(`"es_ES"`),
+		"coordinates": json.RawMessage(`{"lat":40.4,"lng":-3.7}`),
+	}
+
+	if !req.Attributes.Has("locale") || req.Attributes.Has("timezone") {
+		t.Errorf("unexpected result of Has")
+	}
+
+	locale, err := Attribute[string](req, "locale")
+	if err != nil || locale != "es_ES" {
+		t.Errorf("expected locale es_ES, got %q, %v", locale, err)
+	}
+
+	coords, err := Attribute[struct{ Lat, Lng float64 }](req, "coordinates")
+	if err != nil || coords.Lat != 40.4 || coords.Lng != -3.7 {
+		t.Errorf("unexpected coordinates %v, %v", coords, err)
+	}
+
+	var attrErr *AttributeError
+	if _, err := Attribute[int](req, "locale"); !errors.As(err, &attrErr) {
+		t.Errorf("expected an AttributeError for an invalid type, got %v", err)
+	}
+
+	if _, err := Attribute[string](req, "timezone"); !errors.As(err, &attrErr) {
+		t.Errorf("expected an AttributeError for a missing attribute, got %v", err)
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := &Schema{
+		Attributes: map[string]AttributeSchema{
+			"locale": {Type: StringAttribute, Required: true},
+			"device": {Type: ObjectAttribute},
+			"extra":  {},
+			"retries": {Type: NumberAttribute, Validate: func(raw json.RawMessage) error {
+				if string(raw) == "0" {
+					return errors.New("must not be zero")
+				}
+				return nil
+			}},
+		},
+	}
+
+	cases := []struct {
+		input    string
+		problems []string
+	}{
+		{`{"locale":"en","device":{"os":"ios"},"extra":[1],"retries":3}`, nil},
+		{`{"device":"ios"}`, []string{"device", "locale"}},
+		{`{"locale":null,"retries":0}`, []string{"locale", "retries"}},
+		{`{"locale":"en","unknown":true}`, []string{"unknown"}},
+	}
+
+	for _, c := range cases {
+		var attrs Attributes
+		json.Unmarshal([]byte(c.input), &attrs)
+
+		var problems []string
+		if err := schema.Validate(attrs); err != nil {
+			for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+				problems = append(problems, err.(*AttributeError).Name)
+			}
+		}
+
+		if !reflect.DeepEqual(problems, c.problems) {
+			t.Errorf("%s: expected problems with %v, got %v", c.input, c.problems, problems)
+		}
+	}
+
+	schema.AllowUnknown = true
+	if err := schema.Validate(Attributes{"locale": json.RawMessage(`"en"`), "unknown": json.RawMessage(`1`)}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+type attributePlugin struct{}
+
+func (p *attributePlugin) Analyze(req *Request) (Score, interface{}) {
+	return NewScore(10, true), nil
+}
+
+func (p *attributePlugin) Process(req *Request, _ interface{}) (interface{}, error) {
+	return req.Attributes, nil
+}
+
+func (p *attributePlugin) Name() string {
+	return "attributes"
+}
+
+func (p *attributePlugin) Precedence() int {
+	return 1
+}
+
+func TestHandlerAttributes(t *testing.T) {
+	server := NewServer(Config{
+		Plugins: []Plugin{&attributePlugin{}},
+		Schema: &Schema{
+			Attributes: map[string]AttributeSchema{
+				"timezone": {Type: StringAttribute, Required: true},
+				"device":   {Type: ObjectAttribute},
+			},
+		},
+	})
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/process", "application/json", strings.NewReader(`{"text":"foo","timezone":"Europe/Madrid","device":{"os":"ios","version":17}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	expected := `{"data":{"device":{"os":"ios","version":17},"timezone":"Europe/Madrid"},"error":false,"type":"attributes"}`
+	if string(body) != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+
+	resp, err = http.Post(ts.URL+"/process", "application/json", strings.NewReader(`{"text":"foo","timezone":3}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "attribute timezone: must be of type string") {
+		t.Errorf("expected schema error, got %d %s", resp.StatusCode, body)
+	}
+}
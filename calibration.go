@@ -0,0 +1,159 @@
+package trevor
+
+import (
+	"math"
+	"sync"
+)
+
+// ScoreStats are the statistics of the raw scores returned by a plugin.
+type ScoreStats struct {
+	// Count is the number of scores recorded.
+	Count int64 `json:"count"`
+
+	// Mean is the mean of the scores.
+	Mean float64 `json:"mean"`
+
+	// StdDev is the standard deviation of the scores.
+	StdDev float64 `json:"std_dev"`
+
+	// Min is the lowest score recorded.
+	Min float64 `json:"min"`
+
+	// Max is the highest score recorded.
+	Max float64 `json:"max"`
+}
+
+// Calibrator transforms the raw score returned by a plugin before the candidates are ranked.
+type Calibrator interface {
+	// Calibrate returns the calibrated score given the raw score and the statistics
+	// of all the raw scores returned by the plugin so far, including this one.
+	Calibrate(score float64, stats ScoreStats) float64
+}
+
+// CalibratorFunc is an adapter to use ordinary functions as calibrators.
+type CalibratorFunc func(float64, ScoreStats) float64
+
+// Calibrate calls f(score, stats).
+func (f CalibratorFunc) Calibrate(score float64, stats ScoreStats) float64 {
+	return f(score, stats)
+}
+
+// Clamp returns a Calibrator that limits the scores to the range [min, max].
+func Clamp(min, max float64) Calibrator {
+	return CalibratorFunc(func(score float64, _ ScoreStats) float64 {
+		return math.Max(min, math.Min(max, score))
+	})
+}
+
+// Scale returns a Calibrator that multiplies the scores by factor and adds offset to them.
+func Scale(factor, offset float64) Calibrator {
+	return CalibratorFunc(func(score float64, _ ScoreStats) float64 {
+		return score*factor + offset
+	})
+}
+
+// MinMax returns a Calibrator that maps the scores from the range of scores
+// recorded for the plugin to the range [0, DefaultMaxScore]. Scores are left
+// untouched until the plugin has returned at least minSamples scores.
+func MinMax(minSamples int64) Calibrator {
+	return CalibratorFunc(func(score float64, stats ScoreStats) float64 {
+		if stats.Count < minSamples || stats.Max == stats.Min {
+			return score
+		}
+
+		return (score - stats.Min) / (stats.Max - stats.Min) * DefaultMaxScore
+	})
+}
+
+// Standardize returns a Calibrator that replaces the scores by their standard
+// score, that is, the number of standard deviations they are above the mean of
+// the scores recorded for the plugin. Scores are left untouched until the plugin
+// has returned at least minSamples scores.
+func Standardize(minSamples int64) Calibrator {
+	return CalibratorFunc(func(score float64, stats ScoreStats) float64 {
+		if stats.Count < minSamples || stats.StdDev == 0 {
+			return score
+		}
+
+		return (score - stats.Mean) / stats.StdDev
+	})
+}
+
+// Calibrators returns a Calibrator that applies all the given calibrators in order.
+func Calibrators(calibrators ...Calibrator) Calibrator {
+	return CalibratorFunc(func(score float64, stats ScoreStats) float64 {
+		for _, c := range calibrators {
+			score = c.Calibrate(score, stats)
+		}
+
+		return score
+	})
+}
+
+// runningStats computes the statistics of a stream of scores using Welford's algorithm.
+type runningStats struct {
+	count    int64
+	mean, m2 float64
+	min, max float64
+}
+
+func (s *runningStats) add(score float64) {
+	if s.count == 0 || score < s.min {
+		s.min = score
+	}
+
+	if s.count == 0 || score > s.max {
+		s.max = score
+	}
+
+	s.count++
+	delta := score - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (score - s.mean)
+}
+
+func (s *runningStats) stats() ScoreStats {
+	stats := ScoreStats{Count: s.count, Mean: s.mean, Min: s.min, Max: s.max}
+	if s.count > 1 {
+		stats.StdDev = math.Sqrt(s.m2 / float64(s.count-1))
+	}
+
+	return stats
+}
+
+// scoreRecorder records the raw scores of all plugins.
+type scoreRecorder struct {
+	sync.Mutex
+	plugins map[string]*runningStats
+}
+
+func newScoreRecorder() *scoreRecorder {
+	return &scoreRecorder{plugins: map[string]*runningStats{}}
+}
+
+// record adds a score of the given plugin and returns the updated statistics.
+func (r *scoreRecorder) record(plugin string, score float64) ScoreStats {
+	r.Lock()
+	defer r.Unlock()
+
+	s, ok := r.plugins[plugin]
+	if !ok {
+		s = &runningStats{}
+		r.plugins[plugin] = s
+	}
+
+	s.add(score)
+	return s.stats()
+}
+
+func (r *scoreRecorder) all() map[string]ScoreStats {
+	r.Lock()
+	defer r.Unlock()
+
+	stats := make(map[string]ScoreStats, len(r.plugins))
+	for plugin, s := range r.plugins {
+		stats[plugin] = s.stats()
+	}
+
+	return stats
+}
@@ -0,0 +1,109 @@
+package trevor
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestClamp(t *testing.T) {
+	clamp := Clamp(0, 10)
+	for score, expected := range map[float64]float64{-1: 0, 5: 5, 100: 10} {
+		if got := clamp.Calibrate(score, ScoreStats{}); got != expected {
+			t.Errorf("expected %g to be clamped to %g, got %g", score, expected, got)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	if got := Scale(0.1, 1).Calibrate(50, ScoreStats{}); got != 6 {
+		t.Errorf("expected scaled score to be 6, got %g", got)
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	stats := ScoreStats{Count: 10, Min: 20, Max: 120}
+	if got := MinMax(5).Calibrate(70, stats); got != 5 {
+		t.Errorf("expected normalized score to be 5, got %g", got)
+	}
+
+	if got := MinMax(20).Calibrate(70, stats); got != 70 {
+		t.Errorf("expected score not to change without enough samples, got %g", got)
+	}
+}
+
+func TestStandardize(t *testing.T) {
+	stats := ScoreStats{Count: 10, Mean: 5, StdDev: 2}
+	if got := Standardize(2).Calibrate(9, stats); got != 2 {
+		t.Errorf("expected standard score to be 2, got %g", got)
+	}
+}
+
+func TestCalibrators(t *testing.T) {
+	if got := Calibrators(Scale(2, 0), Clamp(0, 10)).Calibrate(8, ScoreStats{}); got != 10 {
+		t.Errorf("expected calibrated score to be 10, got %g", got)
+	}
+}
+
+func TestRunningStats(t *testing.T) {
+	var s runningStats
+	for _, score := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		s.add(score)
+	}
+
+	stats := s.stats()
+	if stats.Count != 8 || stats.Mean != 5 || stats.Min != 2 || stats.Max != 9 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if math.Abs(stats.StdDev-2.138) > 0.001 {
+		t.Errorf("expected standard deviation to be 2.138, got %g", stats.StdDev)
+	}
+}
+
+func TestEngineCalibration(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{
+		&indexPlugin{name: "greedy", score: 100, precedence: 1},
+		&indexPlugin{name: "modest", score: 8, precedence: 1},
+	})
+
+	if plugin, _, _ := e.Process(NewRequest("foo", nil)); plugin != "greedy" {
+		t.Errorf("expected greedy plugin to be chosen without calibration, %s was", plugin)
+	}
+
+	e.SetCalibrator(Clamp(0, 10))
+	e.SetPluginCalibrator("greedy", Scale(0.05, 0))
+	if plugin, _, _ := e.Process(NewRequest("foo", nil)); plugin != "modest" {
+		t.Errorf("expected modest plugin to be chosen with calibration, %s was", plugin)
+	}
+
+	stats := e.ScoreStats()
+	if stats["greedy"].Count != 2 || stats["greedy"].Max != 100 {
+		t.Errorf("expected raw scores of greedy plugin to be recorded, got %+v", stats["greedy"])
+	}
+}
+
+func TestSetPluginCalibratorWhileProcessing(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				e.Process(NewRequest("how are you?", nil))
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		e.SetPluginCalibrator("foo", Clamp(0, 5))
+		e.SetPluginCalibrator("foo", nil)
+		e.SetCalibrator(Scale(1, 0))
+	}
+
+	wg.Wait()
+}
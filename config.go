@@ -1,5 +1,12 @@
 package trevor
 
+import (
+	"crypto/tls"
+	"fmt"
+	"reflect"
+	"time"
+)
+
 // Config is the configuration passed to start the Server.
 type Config struct {
 	// Plugins is a list of plugins for the trevor server
@@ -20,21 +27,211 @@ type Config struct {
 	// Secure determines if the server will be run over HTTP or HTTPS
 	Secure bool
 
+	// TLSConfig is the TLS configuration of the server, e.g. to use in-memory certificates, set the minimum
+	// version and cipher suites or require and verify client certificates. If it is set the server is run
+	// over HTTPS even if Secure is false. If it has no certificates, the ones in CertPerm and KeyPerm are used.
+	TLSConfig *tls.Config
+
 	// Endpoint is the endpoint to get the processed data. e.g: http://localhost:8080/get_data
 	Endpoint string
 
 	// InputFieldName is the key of the JSON object passed to the endpoint that contains the input data.
 	InputFieldName string
 
+	// Normalizer is applied to the text of every request before analysing it, e.g. DefaultNormalizer.
+	// The original text is kept.
+	Normalizer Normalizer
+
+	// Tokenizer splits the normalized text of every request into tokens. Defaults to DefaultTokenizer.
+	Tokenizer Tokenizer
+
+	// Extractor finds the entities of every request, e.g. DefaultExtractor. No entities are extracted if it is nil.
+	Extractor Extractor
+
+	// LocaleFieldName is the key of the JSON object passed to the endpoint that contains the locale of the
+	// input, if any. Defaults to "locale".
+	LocaleFieldName string
+
+	// LanguageDetector is the name of the LanguageDetector service used to detect the locale of the inputs
+	// without locale or Accept-Language header.
+	LanguageDetector string
+
+	// Schema describes the rest of the fields of the JSON object passed to the endpoint, which are passed to
+	// the engine as the attributes of the request. If it is nil, any field is accepted.
+	Schema *Schema
+
+	// PluginFieldName is the key of the JSON object passed to the endpoint that contains the name of the plugin
+	// chosen by the client to process the input, if any. Defaults to "plugin".
+	PluginFieldName string
+
+	// PluginsFieldName is the key of the JSON object passed to the endpoint that contains the list of plugins
+	// allowed to process the input, if any. Defaults to "plugins".
+	PluginsFieldName string
+
+	// AdminEndpoint is the endpoint under which the administration endpoints are served, e.g: with "admin"
+	// the engine is reloaded with a POST request to http://localhost:8080/admin/reload. If it is empty, the
+	// administration endpoints are disabled. They must not be exposed to the public.
+	AdminEndpoint string
+
+	// Reload returns the plugins and services that replace the ones of the engine when a reload
+	// is requested to the administration endpoint. If it is nil, reloading is disabled.
+	Reload func() ([]Plugin, []Service, error)
+
 	// CORSOrigin is a comma separated list of origins allowed for CORS.
 	CORSOrigin string
 
 	// KeyPerm is the key for the SSL
 	KeyPerm string
 
-	// CertPerm is the cert for the SSL
+	// CertPerm is the cert for the SSL. The cert and the key are loaded again when any of them changes.
 	CertPerm string
 
-	// Analyzer is the function used as a analyzer for choosing the adequate plugin for the request
+	// Analyzer is the function used as a analyzer for choosing the adequate plugin for the request.
+	// If Router is also set, the Router is asked first.
 	Analyzer Analyzer
+
+	// Calibrator is applied to the scores of all plugins without their own calibrator in Calibrators before ranking.
+	Calibrator Calibrator
+
+	// Calibrators are the calibrators applied to the scores of the plugins before ranking, by plugin name.
+	Calibrators map[string]Calibrator
+
+	// Ranker sorts the candidates to process a request. Defaults to DefaultRanker.
+	Ranker Ranker
+
+	// Router chooses the plugin for every request before falling back to scoring the request with all plugins.
+	Router Router
+
+	// Timeout is the maximum time the engine can spend processing a request. Zero means no limit.
+	Timeout time.Duration
+
+	// PluginTimeout is the maximum time a plugin can spend analyzing or processing a request. Zero means no limit.
+	PluginTimeout time.Duration
+
+	// Concurrency is the maximum number of plugins analysing a request at the same time. Zero means no limit.
+	Concurrency int
+
+	// ShortCircuit makes the engine stop the analysis as soon as a plugin returns an exact match
+	// and no plugin with a higher precedence is still analysing the request.
+	ShortCircuit bool
+
+	// FallbackDepth is the number of next best candidates that will be tried if the chosen plugin fails to process a request.
+	FallbackDepth int
+
+	// MinScore is the minimum score a plugin needs to be chosen to process a request. Exact matches are always considered.
+	// Zero means no minimum score.
+	MinScore float64
+
+	// NoMatchPlugin is the name of the plugin that processes the requests no plugin scored enough for.
+	// If it is empty, those requests are answered with an error.
+	NoMatchPlugin string
+
+	// Alternatives is the number of other candidates returned along with the answer. Zero means none.
+	Alternatives int
+}
+
+// withDefaults returns the config with the default values of the fields that are not set.
+func (c Config) withDefaults() Config {
+	if c.Endpoint == "" {
+		c.Endpoint = "process"
+	}
+
+	if c.InputFieldName == "" {
+		c.InputFieldName = "text"
+	}
+
+	if c.PluginFieldName == "" {
+		c.PluginFieldName = "plugin"
+	}
+
+	if c.PluginsFieldName == "" {
+		c.PluginsFieldName = "plugins"
+	}
+
+	if c.LocaleFieldName == "" {
+		c.LocaleFieldName = "locale"
+	}
+
+	if c.CORSOrigin == "" {
+		c.CORSOrigin = "*"
+	}
+
+	return c
+}
+
+// secretConfigFields are the fields of Config that are redacted when the config is shown.
+var secretConfigFields = map[string]bool{
+	"KeyPerm":   true,
+	"TLSConfig": true,
+}
+
+const redacted = "[REDACTED]"
+
+// configInfo returns a representation of the config that can be encoded as JSON,
+// with all secrets redacted. Plugins, services and middleware are the current ones
+// of the engine, which may have changed after a reload, represented by their names.
+// Functions and other values that can't be encoded are represented by their types.
+func configInfo(c Config, engine EngineInfo) map[string]interface{} {
+	var (
+		info = map[string]interface{}{}
+		v    = reflect.ValueOf(c)
+		t    = v.Type()
+	)
+
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		switch {
+		case secretConfigFields[field.Name]:
+			if value.IsZero() {
+				info[field.Name] = value.Interface()
+			} else {
+				info[field.Name] = redacted
+			}
+		case field.Name == "Plugins":
+			names := make([]string, len(engine.Plugins))
+			for i, plugin := range engine.Plugins {
+				names[i] = plugin.Name
+			}
+			info[field.Name] = names
+		case field.Name == "Services":
+			names := make([]string, len(engine.Services))
+			for i, service := range engine.Services {
+				names[i] = service.Name
+			}
+			info[field.Name] = names
+		case field.Name == "Middleware":
+			info[field.Name] = engine.Middleware
+		default:
+			info[field.Name] = valueInfo(value)
+		}
+	}
+
+	return info
+}
+
+func valueInfo(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Func, reflect.Interface, reflect.Ptr, reflect.Chan:
+		if value.IsNil() {
+			return nil
+		}
+		return fmt.Sprintf("%T", value.Interface())
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+
+		m := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = valueInfo(iter.Value())
+		}
+		return m
+	}
+
+	if d, ok := value.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	return value.Interface()
 }
@@ -1,8 +1,11 @@
 package trevor
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 )
 
 type Engine interface {
@@ -12,129 +15,566 @@ type Engine interface {
 	// SetServices sets the list of services of the engine.
 	SetServices([]Service)
 
-	// SetAnalyzer sets the Analyzer function of the engine.
+	// SetAnalyzer sets the Analyzer function of the engine. It is the same as
+	// using the analyzer as Router.
 	SetAnalyzer(Analyzer)
 
+	// SetRouter sets the Router that will choose the plugin for every request
+	// before falling back to scoring the request with all plugins.
+	SetRouter(Router)
+
+	// SetRanker sets the Ranker used to sort the candidates to process a request.
+	// If it is nil, the DefaultRanker is used.
+	SetRanker(Ranker)
+
+	// SetCalibrator sets the Calibrator applied to the scores of all plugins without their own calibrator.
+	SetCalibrator(Calibrator)
+
+	// SetPluginCalibrator sets the Calibrator applied to the scores of the plugin with the given name.
+	SetPluginCalibrator(string, Calibrator)
+
+	// ScoreStats returns the statistics of the raw scores returned by every plugin.
+	ScoreStats() map[string]ScoreStats
+
+	// EnablePlugin enables the plugin with the given name, so it can process requests again.
+	EnablePlugin(string) error
+
+	// DisablePlugin disables the plugin with the given name. Disabled plugins are not
+	// asked to analyze requests and are skipped by routers. Plugins are still disabled
+	// after a reload.
+	DisablePlugin(string) error
+
+	// Plugins returns the information of all plugins of the engine.
+	Plugins() []PluginInfo
+
+	// Info returns the information of all plugins, services, middleware and pokes of the engine.
+	Info() EngineInfo
+
 	// SetMiddleware sets the list of middleware of the engine.
 	SetMiddleware([]Middleware)
 
+	// SetTimeout sets the maximum time the engine can spend processing a request. Zero means no limit.
+	SetTimeout(time.Duration)
+
+	// SetPluginTimeout sets the maximum time a plugin can spend on a single analysis or process call. Zero means no limit.
+	SetPluginTimeout(time.Duration)
+
+	// SetConcurrency sets the maximum number of plugins analysing a request at the same time. Zero means no limit.
+	SetConcurrency(int)
+
+	// SetShortCircuit enables or disables stopping the analysis as soon as a plugin returns
+	// an exact match and no plugin with a higher precedence is still analysing the request.
+	SetShortCircuit(bool)
+
+	// SetFallbackDepth sets how many of the next best candidates are tried when the chosen plugin
+	// fails to process a request. Zero means no other candidate is tried.
+	SetFallbackDepth(int)
+
+	// SetMinScore sets the minimum score a plugin needs to be chosen to process a request. Exact matches
+	// are always considered. Zero means no minimum score.
+	SetMinScore(float64)
+
+	// SetNoMatchPlugin sets the name of the plugin that will process the requests no plugin scored enough for.
+	SetNoMatchPlugin(string)
+
+	// SetNormalizer sets the Normalizer applied to the text of every request before
+	// analysing it. The result is in the NormalizedText field of the request. If it is
+	// nil the normalized text is the text of the request.
+	SetNormalizer(Normalizer)
+
+	// SetTokenizer sets the Tokenizer used to split the normalized text of every request
+	// into the Tokens field of the request. If it is nil, the DefaultTokenizer is used.
+	SetTokenizer(Tokenizer)
+
+	// SetExtractor sets the Extractor used to find the entities of every request, like
+	// dates or numbers, in the Entities field of the request. If it is nil no entities
+	// are extracted.
+	SetExtractor(Extractor)
+
+	// SetLanguageDetector sets the name of the LanguageDetector service used to detect
+	// the locale of the requests without one.
+	SetLanguageDetector(string)
+
+	// SetAlternatives sets the number of other candidates that will be returned along with
+	// the answer in the Alternatives field of the request. Zero means none.
+	SetAlternatives(int)
+
 	// Process takes the current request to process and returns the name of the plugin that
-	// processed the text and the data returned by it.
+	// processed the text and the data returned by it. Processing stops as soon as the
+	// context of the request is done.
 	Process(*Request) (string, interface{}, error)
 
-	// SchedulePokes schedules all pokes to run indefinitely.
+	// Validate checks the plugins and services of the engine and returns a *ConfigError
+	// with all the problems found, if any.
+	Validate() error
+
+	// Init validates the engine and initializes all plugins and services implementing
+	// Initializer, services in dependency order first and then plugins. It must be called
+	// after setting plugins and services and before processing any request. Only the
+	// first call initializes the components, the rest return the same result.
+	Init() error
+
+	// Reload replaces atomically all plugins and services of the engine. The new plugins
+	// and services are validated and injected and, if the engine has been initialized,
+	// the components that were not part of the engine are initialized. New requests are
+	// processed with the new plugins and services while the requests being processed
+	// finish with the previous ones. Once they have finished, the previous components
+	// that are no longer used are finalized and closed. Pokes are rescheduled if they
+	// were scheduled. If anything fails the engine keeps the previous plugins and services.
+	// Plugins and services that are part of both keep their services and are not injected
+	// again, so the reload fails with a ChangedDependencyError if any of their services
+	// is a different instance; pass new instances of them instead.
+	Reload([]Plugin, []Service) error
+
+	// SchedulePokes schedules all pokes to run until the engine is shut down.
 	SchedulePokes()
 
+	// Shutdown stops accepting new requests, waits for the requests being processed
+	// to finish, stops all poke workers and finalizes and closes all plugins and services
+	// implementing Finalizer or io.Closer, in the reverse order of Init. If the
+	// context is done before all requests finish, the context error is returned and
+	// nothing is closed.
+	Shutdown(context.Context) error
+
 	// Memory returns the memory service if any.
 	Memory() MemoryService
 }
 
-// Analyzer is a function that takes the current request to process and returns the name of the plugin that should process it and metadata.
-type Analyzer func(*Request) (string, interface{})
-
 type engine struct {
-	plugins    []Plugin
-	pluginMap  map[string]int
-	services   map[string]Service
+	active     *snapshot
+	snapshotMu sync.RWMutex
+	state      pluginState
+
 	middleware []Middleware
-	analyzer   Analyzer
-	memory     MemoryService
+	router     Router
+	ranker     Ranker
+
+	calibratorsMu     sync.RWMutex
+	calibrator        Calibrator
+	pluginCalibrators map[string]Calibrator
+	scores            *scoreRecorder
+
+	lifecycle
+
+	timeout       time.Duration
+	pluginTimeout time.Duration
+	concurrency   int
+	shortCircuit  bool
+	fallbackDepth int
+	minScore      float64
+	noMatchPlugin string
+	alternatives  int
+
+	languageDetector string
+	normalizer       Normalizer
+	tokenizer        Tokenizer
+	extractor        Extractor
+}
+
+// NoMatchError is the error returned when no plugin scored enough to process a request
+// and there is no plugin to process the requests that do not match.
+type NoMatchError struct {
+	// MinScore is the minimum score required to process the request.
+	MinScore float64
+
+	// BestScore is the best score returned by the plugins.
+	BestScore float64
+}
+
+func (e *NoMatchError) Error() string {
+	return fmt.Sprintf("no plugin can process the request: best score was %g but the minimum is %g", e.BestScore, e.MinScore)
+}
+
+// UnknownPluginError is the error returned when a plugin is referenced by a name
+// that does not belong to any plugin of the engine.
+type UnknownPluginError struct {
+	// Name is the name of the plugin.
+	Name string
+}
+
+func (e *UnknownPluginError) Error() string {
+	return "unknown plugin: " + e.Name
 }
 
 // NewEngine creates a new Engine instance
 func NewEngine() Engine {
 	return &engine{
-		services:  map[string]Service{},
-		pluginMap: map[string]int{},
+		active:    newSnapshot(),
+		ranker:    DefaultRanker,
+		tokenizer: DefaultTokenizer,
+
+		pluginCalibrators: map[string]Calibrator{},
+		scores:            newScoreRecorder(),
 	}
 }
 
 func (e *engine) SetPlugins(plugins []Plugin) {
-	SortPlugins(plugins)
-	e.plugins = e.injectServices(plugins)
+	e.snapshotMu.Lock()
+	defer e.snapshotMu.Unlock()
+
+	next := e.active.clone()
+	next.setPlugins(plugins)
+	e.active = next
+}
+
+func (e *engine) SetServices(services []Service) {
+	e.snapshotMu.Lock()
+	defer e.snapshotMu.Unlock()
+
+	next := e.active.clone()
+	next.setServices(services)
+	e.active = next
+}
+
+// current returns the snapshot used to process new requests.
+func (e *engine) current() *snapshot {
+	e.snapshotMu.RLock()
+	defer e.snapshotMu.RUnlock()
+
+	return e.active
+}
 
-	for i, p := range e.plugins {
-		e.pluginMap[p.Name()] = i
+// acquire returns the snapshot used to process new requests and marks the start of
+// a request processed with it. The snapshot must be released when the request ends.
+func (e *engine) acquire() *snapshot {
+	e.snapshotMu.RLock()
+	defer e.snapshotMu.RUnlock()
+
+	e.active.requests.Add(1)
+	return e.active
+}
+
+func (s *snapshot) release() {
+	s.requests.Done()
+}
+
+func (e *engine) SetMiddleware(mw []Middleware) {
+	e.middleware = mw
+}
+
+func (e *engine) SetAnalyzer(analyzer Analyzer) {
+	if analyzer == nil {
+		e.router = nil
+	} else {
+		e.router = analyzer
 	}
 }
 
-func (e *engine) getPlugin(name string) Plugin {
-	return e.plugins[e.pluginMap[name]]
+func (e *engine) SetRouter(router Router) {
+	e.router = router
 }
 
-func (e *engine) SetServices(services []Service) {
-	for _, service := range services {
-		e.services[service.Name()] = service
+func (e *engine) SetRanker(ranker Ranker) {
+	if ranker == nil {
+		ranker = DefaultRanker
 	}
 
-	e.setMemoryService()
+	e.ranker = ranker
 }
 
-func (e *engine) setMemoryService() {
-	if service, ok := e.services["memory"]; ok {
-		if memoryService, isMemoryService := service.(MemoryService); isMemoryService && service.Name() == "memory" {
-			storeName := memoryService.NeededStore()
-			if store, ok := e.services[storeName]; ok || storeName == "" {
-				memoryService.SetStore(store)
-			} else {
-				panic(errors.New("service " + storeName + " not found but is required by memory service"))
-			}
+func (e *engine) SetCalibrator(calibrator Calibrator) {
+	e.calibratorsMu.Lock()
+	defer e.calibratorsMu.Unlock()
+
+	e.calibrator = calibrator
+}
+
+func (e *engine) SetPluginCalibrator(name string, calibrator Calibrator) {
+	e.calibratorsMu.Lock()
+	defer e.calibratorsMu.Unlock()
+
+	if calibrator == nil {
+		delete(e.pluginCalibrators, name)
+	} else {
+		e.pluginCalibrators[name] = calibrator
+	}
+}
+
+func (e *engine) ScoreStats() map[string]ScoreStats {
+	return e.scores.all()
+}
 
-			e.memory = memoryService
+// calibrate records the raw scores of the results and replaces them with their calibrated scores.
+func (e *engine) calibrate(results []analysisResult) {
+	for i := range results {
+		stats := e.scores.record(results[i].name, results[i].score)
+
+		if calibrator := e.calibratorFor(results[i].name); calibrator != nil {
+			results[i].score = calibrator.Calibrate(results[i].score, stats)
 		}
 	}
 }
 
-func (e *engine) SetMiddleware(mw []Middleware) {
-	e.middleware = mw
+// calibratorFor returns the calibrator of the plugin with the given name, if any,
+// or the calibrator of all plugins.
+func (e *engine) calibratorFor(name string) Calibrator {
+	e.calibratorsMu.RLock()
+	defer e.calibratorsMu.RUnlock()
+
+	if calibrator, ok := e.pluginCalibrators[name]; ok {
+		return calibrator
+	}
+
+	return e.calibrator
 }
 
-func (e *engine) SetAnalyzer(analyzer Analyzer) {
-	e.analyzer = analyzer
+func (e *engine) SetTimeout(timeout time.Duration) {
+	e.timeout = timeout
+}
+
+func (e *engine) SetPluginTimeout(timeout time.Duration) {
+	e.pluginTimeout = timeout
+}
+
+func (e *engine) SetConcurrency(concurrency int) {
+	e.concurrency = concurrency
+}
+
+func (e *engine) SetShortCircuit(shortCircuit bool) {
+	e.shortCircuit = shortCircuit
+}
+
+func (e *engine) SetFallbackDepth(depth int) {
+	e.fallbackDepth = depth
+}
+
+func (e *engine) SetMinScore(score float64) {
+	e.minScore = score
+}
+
+func (e *engine) SetNoMatchPlugin(name string) {
+	e.noMatchPlugin = name
+}
+
+func (e *engine) SetAlternatives(n int) {
+	e.alternatives = n
 }
 
-func (e *engine) injectServices(plugins []Plugin) []Plugin {
-	for _, plugin := range plugins {
-		if injectablePlugin, ok := plugin.(InjectablePlugin); ok {
-			for _, serviceName := range injectablePlugin.NeededServices() {
-				service, ok := e.services[serviceName]
-				if !ok {
-					panic(fmt.Sprintf("unknown service with name: %s", serviceName))
-				}
+func (e *engine) SetLanguageDetector(name string) {
+	e.languageDetector = name
+}
+
+func (e *engine) SetNormalizer(normalizer Normalizer) {
+	e.normalizer = normalizer
+}
+
+func (e *engine) SetTokenizer(tokenizer Tokenizer) {
+	if tokenizer == nil {
+		tokenizer = DefaultTokenizer
+	}
+
+	e.tokenizer = tokenizer
+}
+
+func (e *engine) SetExtractor(extractor Extractor) {
+	e.extractor = extractor
+}
+
+// normalize sets the normalized text and the tokens of the request.
+func (e *engine) normalize(req *Request) {
+	req.NormalizedText = req.Text
+	if e.normalizer != nil {
+		req.NormalizedText = e.normalizer.Normalize(req.Text)
+	}
+	req.normalized = e.normalizer != nil
+
+	req.Tokens = e.tokenizer.Tokenize(req.NormalizedText)
+}
+
+// extract sets the entities of the request found by the extractor, if any.
+func (e *engine) extract(req *Request) {
+	if e.extractor != nil {
+		req.Entities = e.extractor.Extract(req)
+	}
+}
+
+// pluginDependencies returns the names of the services the given plugin depends on.
+func pluginDependencies(plugin Plugin) []string {
+	var deps []string
+	if injectablePlugin, ok := plugin.(InjectablePlugin); ok {
+		deps = append(deps, injectablePlugin.NeededServices()...)
+	}
+
+	return append(deps, taggedServices(plugin)...)
+}
+
+// candidates returns the results of the analysis of the request sorted from best to worst.
+func (e *engine) candidates(s *snapshot, req *Request) ([]analysisResult, error) {
+	plugins, err := s.allowedPlugins(req)
+	if err != nil {
+		return nil, err
+	}
 
-				injectablePlugin.SetService(serviceName, service)
+	plugins = supportedPlugins(e.state.enabledPlugins(plugins), req.Locale)
+
+	if req.Plugin != "" {
+		return e.chosenCandidate(s, req)
+	}
+
+	if e.router != nil {
+		name, metadata, err := e.router.Route(req)
+		if err == nil && !e.state.isEnabled(name) {
+			// routes to disabled plugins fall back to scoring
+			err = ErrNoRoute
+		}
+
+		if err == nil {
+			if _, ok := s.pluginMap[name]; !ok {
+				return nil, &UnknownPluginError{Name: name}
 			}
+
+			if !isAllowed(req, name) {
+				return nil, fmt.Errorf("plugin %s is not allowed to process the request", name)
+			}
+
+			return []analysisResult{{name: name, metadata: metadata}}, nil
+		} else if err != ErrNoRoute {
+			return nil, err
 		}
 	}
 
-	return plugins
+	ctx := req.Context()
+	results := getResults(ctx, plugins, req, analysisOptions{
+		timeout:      e.pluginTimeout,
+		concurrency:  e.concurrency,
+		shortCircuit: e.shortCircuit,
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	e.calibrate(results)
+	rankResults(results, e.ranker)
+	candidates := filterByScore(results, e.minScore)
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	// the plugin for requests that do not match must be enabled, allowed and support the locale
+	if e.noMatchPlugin != "" {
+		for _, plugin := range plugins {
+			if plugin.Name() == e.noMatchPlugin {
+				return []analysisResult{{name: e.noMatchPlugin}}, nil
+			}
+		}
+	}
+
+	noMatch := &NoMatchError{MinScore: e.minScore}
+	if len(results) > 0 {
+		noMatch.BestScore = results[0].score
+	}
+
+	return nil, noMatch
+}
+
+// chosenCandidate returns the plugin chosen by the client as the only candidate,
+// analysing the request only with that plugin to get its metadata.
+func (e *engine) chosenCandidate(s *snapshot, req *Request) ([]analysisResult, error) {
+	plugin, err := s.getPlugin(req.Plugin)
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.state.isEnabled(req.Plugin) {
+		return nil, &DisabledPluginError{Name: req.Plugin}
+	}
+
+	if !isAllowed(req, req.Plugin) {
+		return nil, fmt.Errorf("plugin %s is not allowed to process the request", req.Plugin)
+	}
+
+	score, metadata, err := analyzePlugin(req.Context(), plugin, req, e.pluginTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []analysisResult{
+		newAnalysisResult(score.Score(), score.IsExactMatch(), plugin.Precedence(), plugin.Name(), metadata),
+	}
+	e.calibrate(results)
+
+	return results, nil
 }
 
-func (e *engine) getService(name string) Service {
-	return e.services[name]
+// isAllowed reports whether the client allows the given plugin to process the request.
+func isAllowed(req *Request, name string) bool {
+	if len(req.AllowedPlugins) == 0 {
+		return true
+	}
+
+	for _, allowed := range req.AllowedPlugins {
+		if allowed == name {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (e *engine) process(req *Request) (string, interface{}, error) {
-	var bestResult analysisResult
-	if e.analyzer == nil {
-		bestResult = getBestResult(getResults(e.plugins, req))
-	} else {
-		name, metadata := e.analyzer(req)
-		bestResult = analysisResult{name: name, metadata: metadata}
+func (e *engine) process(s *snapshot, req *Request) (string, interface{}, error) {
+	ranked, err := e.candidates(s, req)
+	if err != nil {
+		return "", nil, err
+	}
+
+	candidates := ranked
+	if len(candidates) > e.fallbackDepth+1 {
+		candidates = candidates[:e.fallbackDepth+1]
 	}
 
-	var chosenPlugin = e.getPlugin(bestResult.name)
-	data, err := chosenPlugin.Process(req, bestResult.metadata)
-	return chosenPlugin.Name(), data, err
+	var (
+		ctx  = req.Context()
+		name string
+		data interface{}
+	)
+
+	for _, candidate := range candidates {
+		var chosenPlugin Plugin
+		if chosenPlugin, err = s.getPlugin(candidate.name); err != nil {
+			return "", nil, err
+		}
+
+		name = chosenPlugin.Name()
+		data, err = processPlugin(ctx, chosenPlugin, req, candidate.metadata, e.pluginTimeout)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+
+		req.Failed = append(req.Failed, PluginError{Plugin: name, Err: err})
+	}
+
+	if err == nil && e.alternatives > 0 {
+		req.Alternatives = alternatives(ranked, name, req.Failed, e.alternatives)
+	}
+
+	return name, data, err
 }
 
 func (e *engine) Process(req *Request) (string, interface{}, error) {
-	if len(e.plugins) == 0 {
+	s := e.acquire()
+	defer s.release()
+
+	if len(s.plugins) == 0 {
 		return "", nil, errors.New("no plugins found. can't process anything")
 	}
 
-	if e.middleware == nil {
-		e.middleware = []Middleware{}
+	if !e.begin() {
+		return "", nil, ErrEngineShutdown
+	}
+	defer e.end()
+
+	e.resolveLocale(s, req)
+	e.normalize(req)
+	e.extract(req)
+
+	if e.timeout > 0 {
+		parent := req.ctx
+		ctx, cancel := context.WithTimeout(req.Context(), e.timeout)
+		req.ctx = ctx
+		defer func() {
+			cancel()
+			req.ctx = parent
+		}()
 	}
 
 	var (
@@ -145,32 +585,71 @@ func (e *engine) Process(req *Request) (string, interface{}, error) {
 
 	next = func() (string, interface{}, error) {
 		if index >= length {
-			return e.process(req)
+			return e.process(s, req)
 		}
 
 		i := index
 		index++
-		return e.middleware[i](req, e.getService, next)
+		return e.middleware[i](req, s.getService, next)
 	}
 
 	return next()
 }
 
 func (e *engine) Memory() MemoryService {
-	return e.memory
+	return e.current().memory
 }
 
 func (e *engine) SchedulePokes() {
-	for _, pp := range PokablePlugins(e.plugins) {
-		go RunPokeWorker(pp)
+	e.schedulePokes(e.current())
+}
+
+func (e *engine) schedulePokes(s *snapshot) {
+	var pokables = PokablePlugins(s.plugins)
+	pokables = append(pokables, PokableServices(s.orderedServices())...)
+
+	ctx := e.pokeContext()
+	for _, p := range pokables {
+		schedule := e.addPokeSchedule(p)
+		e.pokers.Add(1)
+		go func(p Pokable) {
+			defer e.pokers.Done()
+			runPokeWorker(ctx, p, schedule)
+		}(p)
 	}
+}
 
-	var services = make([]Service, 0, len(e.services))
-	for _, service := range e.services {
-		services = append(services, service)
+// serviceDependencies returns the names of the services the given service depends on.
+func serviceDependencies(service Service) []string {
+	var deps []string
+	if memoryService, ok := service.(MemoryService); ok && memoryService.NeededStore() != "" {
+		deps = append(deps, memoryService.NeededStore())
 	}
 
-	for _, ps := range PokableServices(services) {
-		go RunPokeWorker(ps)
+	if injectableService, ok := service.(InjectableService); ok {
+		deps = append(deps, injectableService.NeededServices()...)
 	}
+
+	return append(deps, taggedServices(service)...)
+}
+
+// injectService injects the dependency with the given name to the service.
+// The store of a memory service is injected using its SetStore method.
+func injectService(service Service, name string, dependency Service) error {
+	if memoryService, ok := service.(MemoryService); ok && memoryService.NeededStore() == name {
+		if err := memoryService.SetStore(dependency); err != nil {
+			return err
+		}
+	}
+
+	if injectableService, ok := service.(InjectableService); ok {
+		for _, needed := range injectableService.NeededServices() {
+			if needed == name {
+				injectableService.SetService(name, dependency)
+				break
+			}
+		}
+	}
+
+	return nil
 }
@@ -1,7 +1,9 @@
 package trevor
 
 import (
+	"context"
 	"errors"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -83,6 +85,65 @@ func (p *barPlugin) SetService(name string, service Service) {
 	}
 }
 
+type slowPlugin struct {
+	delay time.Duration
+}
+
+func (p *slowPlugin) Analyze(req *Request) (Score, interface{}) {
+	time.Sleep(p.delay)
+	return NewScore(10, true), nil
+}
+
+func (p *slowPlugin) Process(req *Request, _ interface{}) (interface{}, error) {
+	time.Sleep(p.delay)
+	return "slow", nil
+}
+
+func (p *slowPlugin) Name() string {
+	return "slow"
+}
+
+func (p *slowPlugin) Precedence() int {
+	return 3
+}
+
+type contextAwarePlugin struct {
+	salutePlugin
+	deadline bool
+}
+
+func (p *contextAwarePlugin) AnalyzeContext(ctx context.Context, req *Request) (Score, interface{}, error) {
+	_, p.deadline = ctx.Deadline()
+	score, metadata := p.Analyze(req)
+	return score, metadata, nil
+}
+
+func (p *contextAwarePlugin) ProcessContext(ctx context.Context, req *Request, metadata interface{}) (interface{}, error) {
+	return p.Process(req, metadata)
+}
+
+func (p *contextAwarePlugin) Timeout() time.Duration {
+	return time.Second
+}
+
+type cannotHandlePlugin struct{}
+
+func (p *cannotHandlePlugin) Analyze(req *Request) (Score, interface{}) {
+	return NewScore(4, false), nil
+}
+
+func (p *cannotHandlePlugin) Process(req *Request, _ interface{}) (interface{}, error) {
+	return nil, ErrCannotHandle
+}
+
+func (p *cannotHandlePlugin) Name() string {
+	return "cannot_handle"
+}
+
+func (p *cannotHandlePlugin) Precedence() int {
+	return 1
+}
+
 //
 // Test services
 //
@@ -146,7 +207,7 @@ func TestSetServices(t *testing.T) {
 	e.SetServices(dummyServices())
 
 	for _, s := range []string{"foo", "bar"} {
-		if service, ok := e.services[s]; !ok || service.Name() != s {
+		if service, ok := e.current().services[s]; !ok || service.Name() != s {
 			t.Errorf("expected to find %s service", s)
 		}
 	}
@@ -157,21 +218,25 @@ func TestInjectServices(t *testing.T) {
 	e.SetServices([]Service{&barService{}})
 	e.SetPlugins([]Plugin{&barPlugin{}})
 
-	plugin := e.plugins[0].(*barPlugin)
+	plugin := e.current().plugins[0].(*barPlugin)
 	if plugin.service == nil || plugin.service.Name() != "bar" {
 		t.Errorf("expected to find service bar in bar plugin")
 	}
 }
 
 func TestInjectServicesServiceUnknown(t *testing.T) {
-	defer func() {
-		if r := recover(); r == nil {
-			t.Error("expected a panic!")
-		}
-	}()
-
 	e := NewEngine()
 	e.SetPlugins([]Plugin{&barPlugin{}})
+
+	err := e.Validate()
+	if err == nil {
+		t.Fatal("expected an error!")
+	}
+
+	configErr := err.(*ConfigError)
+	if missing, ok := configErr.Errors[0].(*MissingServiceError); !ok || missing.Service != "bar" || missing.Component != "plugin bar" {
+		t.Errorf("expected missing service error, got %v", configErr.Errors[0])
+	}
 }
 
 func TestSchedulePokes(t *testing.T) {
@@ -183,11 +248,11 @@ func TestSchedulePokes(t *testing.T) {
 
 	time.Sleep(250 * time.Millisecond)
 
-	if e.plugins[0].(*fooPlugin).poked < 4 {
+	if e.current().plugins[0].(*fooPlugin).poked < 4 {
 		t.Errorf("plugin should have been poked at least 4 times")
 	}
 
-	if e.services["foo"].(*fooService).poked < 3 {
+	if e.current().services["foo"].(*fooService).poked < 3 {
 		t.Errorf("service should have been poked 3 times")
 	}
 }
@@ -207,15 +272,21 @@ func TestAnalyzer(t *testing.T) {
 }
 
 func TestSetMemoryServiceWithoutStore(t *testing.T) {
-	defer func() {
-		if r := recover(); r == nil {
-			t.Error("expected a panic!")
-		}
-	}()
-
 	e := NewEngine().(*engine)
 	e.SetPlugins(dummyPlugins())
 	e.SetServices([]Service{&memoryService{}})
+
+	if err := e.Validate(); err == nil {
+		t.Error("expected an error!")
+	}
+
+	if err := e.Init(); err == nil {
+		t.Error("expected init to fail!")
+	}
+
+	if e.Memory() != nil {
+		t.Error("expected memory service not to be set without store")
+	}
 }
 
 func TestMiddleware(t *testing.T) {
@@ -260,8 +331,230 @@ func TestMiddleware(t *testing.T) {
 		t.Errorf("expected middleware to have been called 3 times, called %d instead", beforeCalled)
 	}
 
-	if e.services["foo"].(*fooService).poked != 3 {
-		t.Errorf("expected foo service to have been poked 3 times by the middleware, poked %d instead", e.services["foo"].(*fooService).poked)
+	if e.current().services["foo"].(*fooService).poked != 3 {
+		t.Errorf("expected foo service to have been poked 3 times by the middleware, poked %d instead", e.current().services["foo"].(*fooService).poked)
+	}
+}
+
+func TestPluginTimeout(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{&slowPlugin{50 * time.Millisecond}, &salutePlugin{}})
+	e.SetPluginTimeout(10 * time.Millisecond)
+
+	plugin, _, err := e.Process(NewRequest("how are you?", nil))
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if plugin != "salute" {
+		t.Errorf("expected salute plugin to process but %s plugin did", plugin)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{&slowPlugin{50 * time.Millisecond}})
+	e.SetTimeout(10 * time.Millisecond)
+
+	start := time.Now()
+	_, _, err := e.Process(NewRequest("how are you?", nil))
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+
+	if time.Since(start) > 40*time.Millisecond {
+		t.Errorf("expected engine to give up after the timeout")
+	}
+}
+
+func TestProcessCancelled(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{&slowPlugin{50 * time.Millisecond}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := e.Process(NewRequestWithContext(ctx, "how are you?", nil))
+	if err != context.Canceled {
+		t.Errorf("expected canceled error, got %v", err)
+	}
+}
+
+func TestContextPlugin(t *testing.T) {
+	plugin := &contextAwarePlugin{}
+	e := NewEngine()
+	e.SetPlugins([]Plugin{plugin})
+
+	name, data, err := e.Process(NewRequest("how are you?", nil))
+	if err != nil || name != "salute" || data != "fine, and you?" {
+		t.Errorf("unexpected result from context plugin: %s, %v, %v", name, data, err)
+	}
+
+	if !plugin.deadline {
+		t.Errorf("expected plugin timeout to be used as deadline")
+	}
+}
+
+func TestFallback(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+	e.SetFallbackDepth(1)
+
+	req := NewRequest("foo", nil)
+	plugin, data, err := e.Process(req)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if plugin != "salute" || data != "fine, and you?" {
+		t.Errorf("expected salute plugin to process but %s plugin did", plugin)
+	}
+
+	if len(req.Failed) != 1 || req.Failed[0].Plugin != "foo" {
+		t.Errorf("expected foo plugin to be reported as failed, got %v", req.Failed)
+	}
+}
+
+func TestFallbackAlternatives(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+	e.SetFallbackDepth(1)
+	e.SetAlternatives(1)
+
+	req := NewRequest("foo", nil)
+	if plugin, _, err := e.Process(req); err != nil || plugin != "salute" {
+		t.Fatalf("expected salute plugin to process, got %s and %v", plugin, err)
+	}
+
+	if len(req.Alternatives) != 0 {
+		t.Errorf("expected failed plugins not to be alternatives, got %v", req.Alternatives)
+	}
+}
+
+func TestFallbackExhausted(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{&fooPlugin{}, &cannotHandlePlugin{}, &salutePlugin{}})
+	e.SetFallbackDepth(1)
+
+	req := NewRequest("foo", nil)
+	plugin, _, err := e.Process(req)
+	if err != ErrCannotHandle {
+		t.Errorf("expected cannot handle error, got %v", err)
+	}
+
+	if plugin != "cannot_handle" {
+		t.Errorf("expected cannot_handle plugin to be the last one tried, %s found", plugin)
+	}
+
+	if len(req.Failed) != 2 {
+		t.Errorf("expected 2 failed plugins, got %v", req.Failed)
+	}
+}
+
+func TestMinScore(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+	e.SetMinScore(6)
+
+	_, _, err := e.Process(NewRequest("foo", nil))
+	noMatch, ok := err.(*NoMatchError)
+	if !ok {
+		t.Fatalf("expected no match error, got %v", err)
+	}
+
+	if noMatch.BestScore != 5 || noMatch.MinScore != 6 {
+		t.Errorf("expected best score 5 and min score 6, got %g and %g", noMatch.BestScore, noMatch.MinScore)
+	}
+
+	plugin, _, err := e.Process(NewRequest("how are you?", nil))
+	if err != nil || plugin != "salute" {
+		t.Errorf("expected exact match to be processed by salute plugin, got %s and %v", plugin, err)
+	}
+}
+
+func TestNoMatchPlugin(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+	e.SetMinScore(6)
+	e.SetNoMatchPlugin("salute")
+
+	plugin, _, err := e.Process(NewRequest("foo", nil))
+	if err != nil || plugin != "salute" {
+		t.Errorf("expected salute plugin to process unmatched request, got %s and %v", plugin, err)
+	}
+}
+
+func TestNoMatchPluginNotAllowed(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+	e.SetMinScore(6)
+	e.SetNoMatchPlugin("salute")
+
+	req := NewRequest("foo", nil)
+	req.AllowedPlugins = []string{"foo"}
+	if _, _, err := e.Process(req); !errors.As(err, new(*NoMatchError)) {
+		t.Errorf("expected a NoMatchError when the no match plugin is not allowed, got %v", err)
+	}
+}
+
+func TestAlternatives(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{&fooPlugin{}, &cannotHandlePlugin{}, &salutePlugin{}})
+	e.SetAlternatives(1)
+
+	req := NewRequest("how are you?", nil)
+	plugin, _, err := e.Process(req)
+	if err != nil || plugin != "salute" {
+		t.Fatalf("expected salute plugin to process, got %s and %v", plugin, err)
+	}
+
+	expected := []Candidate{{Plugin: "foo", Score: 5, Precedence: 1}}
+	if !reflect.DeepEqual(req.Alternatives, expected) {
+		t.Errorf("expected alternatives to be %v, got %v", expected, req.Alternatives)
+	}
+}
+
+func TestChosenPlugin(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+
+	req := NewRequest("foo", nil)
+	req.Plugin = "salute"
+	plugin, data, err := e.Process(req)
+	if err != nil || plugin != "salute" || data != "fine, and you?" {
+		t.Errorf("expected salute plugin to process, got %s and %v", plugin, err)
+	}
+
+	req = NewRequest("foo", nil)
+	req.Plugin = "unknown"
+	if _, _, err = e.Process(req); err == nil {
+		t.Errorf("expected error for unknown plugin")
+	}
+
+	req = NewRequest("foo", nil)
+	req.Plugin = "foo"
+	req.AllowedPlugins = []string{"salute"}
+	if _, _, err = e.Process(req); err == nil {
+		t.Errorf("expected error for plugin not allowed")
+	}
+}
+
+func TestAllowedPlugins(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{&fooPlugin{}, &cannotHandlePlugin{}, &salutePlugin{}})
+
+	req := NewRequest("foo", nil)
+	req.AllowedPlugins = []string{"salute", "cannot_handle"}
+	plugin, _, _ := e.Process(req)
+	if plugin != "cannot_handle" {
+		t.Errorf("expected cannot_handle plugin to process, %s did", plugin)
+	}
+
+	req = NewRequest("foo", nil)
+	req.AllowedPlugins = []string{"salute", "unknown"}
+	_, _, err := e.Process(req)
+	if unknown, ok := err.(*UnknownPluginError); !ok || unknown.Name != "unknown" {
+		t.Errorf("expected unknown plugin error, got %v", err)
 	}
 }
 
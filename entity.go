@@ -0,0 +1,438 @@
+package trevor
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EntityType is the type of an entity found in the text of a request.
+type EntityType string
+
+const (
+	// DateEntity is a date or time, absolute or relative, e.g. "tomorrow at 5pm". Its value is a time.Time.
+	DateEntity EntityType = "date"
+	// NumberEntity is a number, e.g. "3.5" or "twelve". Its value is a float64.
+	NumberEntity EntityType = "number"
+	// DurationEntity is a lapse of time, e.g. "10 minutes". Its value is a time.Duration.
+	DurationEntity EntityType = "duration"
+	// QuantityEntity is an amount of some unit, e.g. "2 kg". Its value is a Quantity.
+	QuantityEntity EntityType = "quantity"
+	// EmailEntity is an email address. Its value is the address.
+	EmailEntity EntityType = "email"
+	// URLEntity is a URL. Its value is the URL.
+	URLEntity EntityType = "url"
+)
+
+// Entity is a piece of the text of a request with a meaning, like a date or a number.
+type Entity struct {
+	// Type is the type of the entity.
+	Type EntityType `json:"type"`
+
+	// Text is the text of the entity.
+	Text string `json:"text"`
+
+	// Start and End are the byte offsets of the entity in the text of the request.
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	// Value is the typed value of the entity, which depends on its type.
+	Value interface{} `json:"value"`
+}
+
+// Quantity is the value of a QuantityEntity.
+type Quantity struct {
+	// Amount is the amount of the unit.
+	Amount float64 `json:"amount"`
+
+	// Unit is the unit in lowercase, e.g. "kg" or "%".
+	Unit string `json:"unit"`
+}
+
+// Entities are the entities found in the text of a request, sorted by their position.
+type Entities []Entity
+
+// OfType returns the entities of the given type.
+func (e Entities) OfType(t EntityType) Entities {
+	var entities Entities
+	for _, entity := range e {
+		if entity.Type == t {
+			entities = append(entities, entity)
+		}
+	}
+
+	return entities
+}
+
+// First returns the first entity of the given type, if any.
+func (e Entities) First(t EntityType) (Entity, bool) {
+	for _, entity := range e {
+		if entity.Type == t {
+			return entity, true
+		}
+	}
+
+	return Entity{}, false
+}
+
+// Extractor finds entities in the text of a request.
+type Extractor interface {
+	// Extract returns the entities found in the text of the request.
+	Extract(*Request) Entities
+}
+
+// ExtractorFunc is an adapter to use ordinary functions as extractors.
+type ExtractorFunc func(*Request) Entities
+
+// Extract calls f(req).
+func (f ExtractorFunc) Extract(req *Request) Entities {
+	return f(req)
+}
+
+// Extractors returns an Extractor with the entities of all the given extractors sorted
+// by position. When entities overlap only the longest one is kept, or the one of the
+// first extractor if they are equally long.
+func Extractors(extractors ...Extractor) Extractor {
+	return ExtractorFunc(func(req *Request) Entities {
+		var all Entities
+		for _, extractor := range extractors {
+			all = append(all, extractor.Extract(req)...)
+		}
+
+		sort.SliceStable(all, func(i, j int) bool {
+			if all[i].Start != all[j].Start {
+				return all[i].Start < all[j].Start
+			}
+			return all[i].End-all[i].Start > all[j].End-all[j].Start
+		})
+
+		var entities Entities
+		for _, entity := range all {
+			if n := len(entities); n > 0 && entity.Start < entities[n-1].End {
+				if entity.End-entity.Start <= entities[n-1].End-entities[n-1].Start {
+					continue
+				}
+				entities = entities[:n-1]
+			}
+			entities = append(entities, entity)
+		}
+
+		return entities
+	})
+}
+
+// regexpExtractor returns an Extractor of entities of the given type matching the given
+// expression, with the value returned by fn. Matches for which fn fails are skipped.
+func regexpExtractor(t EntityType, re *regexp.Regexp, fn func(match []string) (interface{}, bool)) Extractor {
+	return ExtractorFunc(func(req *Request) Entities {
+		var entities Entities
+		for _, loc := range re.FindAllStringSubmatchIndex(req.Text, -1) {
+			match := make([]string, len(loc)/2)
+			for i := range match {
+				if loc[2*i] >= 0 {
+					match[i] = req.Text[loc[2*i]:loc[2*i+1]]
+				}
+			}
+
+			if value, ok := fn(match); ok {
+				entities = append(entities, Entity{
+					Type:  t,
+					Text:  match[0],
+					Start: loc[0],
+					End:   loc[1],
+					Value: value,
+				})
+			}
+		}
+
+		return entities
+	})
+}
+
+var (
+	emailExpr = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	urlExpr   = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]*[^\s<>".,;:!?)']`)
+)
+
+// Emails is an Extractor of email addresses.
+var Emails = regexpExtractor(EmailEntity, emailExpr, func(match []string) (interface{}, bool) {
+	return match[0], true
+})
+
+// URLs is an Extractor of URLs starting with http://, https:// or www.
+var URLs = regexpExtractor(URLEntity, urlExpr, func(match []string) (interface{}, bool) {
+	return match[0], true
+})
+
+// numberWords are the numbers that can be written with words.
+var numberWords = map[string]float64{
+	"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7,
+	"eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14,
+	"fifteen": 15, "sixteen": 16, "seventeen": 17, "eighteen": 18, "nineteen": 19, "twenty": 20,
+	"thirty": 30, "forty": 40, "fifty": 50, "sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+	"hundred": 100, "a": 1, "an": 1,
+}
+
+const numberPattern = `(-?\d+(?:\.\d+)?|zero|one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve|thirteen|fourteen|fifteen|sixteen|seventeen|eighteen|nineteen|twenty|thirty|forty|fifty|sixty|seventy|eighty|ninety|hundred)`
+
+// parseNumber returns the value of a number written with digits or words.
+func parseNumber(s string) (float64, bool) {
+	if n, ok := numberWords[strings.ToLower(s)]; ok {
+		return n, true
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+var numberExpr = regexp.MustCompile(`(?i)(?:^|[^\w.])` + numberPattern + `\b`)
+
+// Numbers is an Extractor of numbers written with digits or words.
+var Numbers = ExtractorFunc(func(req *Request) Entities {
+	var entities Entities
+	for _, loc := range numberExpr.FindAllStringSubmatchIndex(req.Text, -1) {
+		text := req.Text[loc[2]:loc[3]]
+		if value, ok := parseNumber(text); ok {
+			entities = append(entities, Entity{Type: NumberEntity, Text: text, Start: loc[2], End: loc[3], Value: value})
+		}
+	}
+
+	return entities
+})
+
+// durationUnits are the units of time and the duration they stand for.
+var durationUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"day": 24 * time.Hour, "days": 24 * time.Hour,
+	"week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// durationUnitPattern matches a unit of time after an amount, including the spaces
+// between them. Single letter units must be attached to the amount, e.g. "5h".
+const durationUnitPattern = `(\s*(?:seconds|second|secs|sec|minutes|minute|mins|min|hours|hour|hrs|hr|days|day|weeks|week)|s|h)`
+
+var durationExpr = regexp.MustCompile(`(?i)\b(?:(half an? hour)|(?:` + numberPattern + `|an?)` + durationUnitPattern + `)\b`)
+
+// parseDuration returns the duration of the given amount of the given unit. An empty
+// amount stands for "a" or "an".
+func parseDuration(amount, unit string) (time.Duration, bool) {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	if !validUnit(amount, unit) {
+		return 0, false
+	}
+
+	n := 1.0
+	if amount != "" {
+		var ok bool
+		if n, ok = parseNumber(amount); !ok {
+			return 0, false
+		}
+	}
+
+	return time.Duration(n * float64(durationUnits[unit])), true
+}
+
+// validUnit reports whether the unit can follow the amount. Single letter units are
+// only valid after numbers written with digits, so words like "ah" or "tenm" are not
+// taken for quantities.
+func validUnit(amount, unit string) bool {
+	if len(unit) > 1 {
+		return true
+	}
+
+	return amount != "" && amount[len(amount)-1] >= '0' && amount[len(amount)-1] <= '9'
+}
+
+// Durations is an Extractor of lapses of time, e.g. "10 minutes", "an hour" or "half an hour".
+var Durations = regexpExtractor(DurationEntity, durationExpr, func(match []string) (interface{}, bool) {
+	if match[1] != "" {
+		return 30 * time.Minute, true
+	}
+
+	return parseDuration(match[2], match[3])
+})
+
+// quantityExpr matches an amount and its unit. Single letter units must be attached to
+// the amount, e.g. "5m", and there is no "in" for inches, as they are common words.
+var quantityExpr = regexp.MustCompile(`(?i)\b` + numberPattern + `(\s*(?:kg|kilograms?|kilos?|grams?|mg|lbs?|pounds?|oz|ounces?|km|kilometers?|kilometres?|meters?|metres?|cm|mm|mi|miles?|ft|feet|foot|inches|inch|liters?|litres?|ml|°c|°f|%|percent|€|\$|euros?|dollars?)|g|m|l)(?:\b|$|\s)`)
+
+// currencyExpr matches an amount of money with the currency symbol before it, e.g. "$5".
+var currencyExpr = regexp.MustCompile(`([$€])\s*(\d+(?:\.\d+)?)\b`)
+
+// Quantities is an Extractor of amounts of units of weight, length, volume, temperature,
+// percentages and currencies, e.g. "2 kg", "5m", "20%" or "$5".
+var Quantities = Extractors(unitQuantities, currencies)
+
+// currencies is an Extractor of amounts of money with the currency symbol before them.
+var currencies = regexpExtractor(QuantityEntity, currencyExpr, func(match []string) (interface{}, bool) {
+	amount, ok := parseNumber(match[2])
+	return Quantity{Amount: amount, Unit: match[1]}, ok
+})
+
+// unitQuantities is an Extractor of amounts followed by their unit.
+var unitQuantities = ExtractorFunc(func(req *Request) Entities {
+	var entities Entities
+	for _, loc := range quantityExpr.FindAllStringSubmatchIndex(req.Text, -1) {
+		unit := strings.ToLower(strings.TrimSpace(req.Text[loc[4]:loc[5]]))
+		amount, ok := parseNumber(req.Text[loc[2]:loc[3]])
+		if !ok || !validUnit(req.Text[loc[2]:loc[3]], unit) {
+			continue
+		}
+
+		entities = append(entities, Entity{
+			Type:  QuantityEntity,
+			Text:  req.Text[loc[2]:loc[5]],
+			Start: loc[2],
+			End:   loc[5],
+			Value: Quantity{Amount: amount, Unit: unit},
+		})
+	}
+
+	return entities
+})
+
+var (
+	weekdays = map[string]time.Weekday{
+		"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+		"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	}
+
+	timeOfDayPattern = `(?:\s+at\s+(\d{1,2})(?::(\d{2}))?\s*(am|pm)?)?`
+	dayExpr          = regexp.MustCompile(`(?i)\b(?:(the day after tomorrow|today|tonight|tomorrow|yesterday)|(?:(next|last|on|this)\s+)?(sunday|monday|tuesday|wednesday|thursday|friday|saturday)|(\d{4})-(\d{2})-(\d{2}))\b` + timeOfDayPattern)
+	atExpr           = regexp.MustCompile(`(?i)\bat\s+(\d{1,2})(?::(\d{2}))?\s*(am|pm)\b|\bat\s+(\d{1,2}):(\d{2})\b`)
+	relativeExpr     = regexp.MustCompile(`(?i)\b(?:in\s+(?:` + numberPattern + `|an?)` + durationUnitPattern + `|(?:` + numberPattern + `|an?)` + durationUnitPattern + `\s+ago)\b`)
+)
+
+// Dates is an Extractor of dates and times relative to the current time, like
+// "tomorrow at 5pm", "next friday", "in 3 days", "2 hours ago" or "2024-05-01".
+var Dates = NewDateExtractor(time.Now)
+
+// NewDateExtractor returns an Extractor of dates like Dates that computes the relative
+// dates from the time returned by now, in its location.
+func NewDateExtractor(now func() time.Time) Extractor {
+	return ExtractorFunc(func(req *Request) Entities {
+		ref := now()
+		days := regexpExtractor(DateEntity, dayExpr, func(m []string) (interface{}, bool) {
+			day, ok := parseDay(ref, m[1], m[2], m[3], m[4], m[5], m[6])
+			if !ok {
+				return nil, false
+			}
+
+			if m[7] == "" && strings.EqualFold(m[1], "tonight") {
+				return time.Date(day.Year(), day.Month(), day.Day(), 21, 0, 0, 0, ref.Location()), true
+			}
+
+			return atTimeOfDay(day, m[7], m[8], m[9])
+		})
+
+		times := regexpExtractor(DateEntity, atExpr, func(m []string) (interface{}, bool) {
+			if m[4] != "" {
+				return atTimeOfDay(ref, m[4], m[5], "")
+			}
+			return atTimeOfDay(ref, m[1], m[2], m[3])
+		})
+
+		relative := regexpExtractor(DateEntity, relativeExpr, func(m []string) (interface{}, bool) {
+			amount, unit, sign := m[1], m[2], time.Duration(1)
+			if unit == "" {
+				amount, unit, sign = m[3], m[4], -1
+			}
+
+			d, ok := parseDuration(amount, unit)
+			return ref.Add(sign * d), ok
+		})
+
+		return Extractors(days, relative, times).Extract(req)
+	})
+}
+
+// parseDay returns the start of the day described by the given parts of a date expression.
+func parseDay(ref time.Time, word, modifier, weekday, year, month, day string) (time.Time, bool) {
+	today := time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, ref.Location())
+	switch {
+	case word != "":
+		offset := map[string]int{"today": 0, "tonight": 0, "tomorrow": 1, "yesterday": -1, "the day after tomorrow": 2}
+		return today.AddDate(0, 0, offset[strings.ToLower(word)]), true
+	case weekday != "":
+		diff := int(weekdays[strings.ToLower(weekday)] - today.Weekday())
+		switch strings.ToLower(modifier) {
+		case "last":
+			if diff >= 0 {
+				diff -= 7
+			}
+		case "next":
+			if diff <= 0 {
+				diff += 7
+			}
+		default:
+			if diff < 0 {
+				diff += 7
+			}
+		}
+		return today.AddDate(0, 0, diff), true
+	default:
+		y, _ := strconv.Atoi(year)
+		m, _ := strconv.Atoi(month)
+		d, _ := strconv.Atoi(day)
+		date := time.Date(y, time.Month(m), d, 0, 0, 0, 0, ref.Location())
+		return date, date.Year() == y && int(date.Month()) == m && date.Day() == d
+	}
+}
+
+// atTimeOfDay returns the given day at the given hour and minute, if any.
+func atTimeOfDay(day time.Time, hour, minute, meridiem string) (interface{}, bool) {
+	if hour == "" {
+		return day, true
+	}
+
+	h, _ := strconv.Atoi(hour)
+	m, _ := strconv.Atoi(minute)
+	if meridiem != "" && h > 12 {
+		return nil, false
+	}
+
+	switch strings.ToLower(meridiem) {
+	case "am":
+		if h == 12 {
+			h = 0
+		}
+	case "pm":
+		if h < 12 {
+			h += 12
+		}
+	}
+
+	if h > 23 || m > 59 || (meridiem != "" && (h == 0 && hour != "12")) {
+		return nil, false
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, day.Location()), true
+}
+
+// DefaultExtractor extracts dates, durations, quantities, numbers, emails and URLs.
+var DefaultExtractor = Extractors(URLs, Emails, Dates, Durations, Quantities, Numbers)
+
+// HasEntities returns a Rule that matches the requests with entities of any of the given
+// types. The score is proportional to the number of types found, and the text of the first
+// entity of every type found is extracted as a slot named after the type. The engine must
+// have an Extractor for the request to have entities.
+func HasEntities(types ...EntityType) Rule {
+	return RuleFunc(func(req *Request) (Match, bool) {
+		slots := Slots{}
+		for _, t := range types {
+			if entity, ok := req.Entities.First(t); ok {
+				slots[string(t)] = entity.Text
+			}
+		}
+
+		if len(slots) == 0 {
+			return Match{}, false
+		}
+
+		return Match{Score: DefaultMaxScore * float64(len(slots)) / float64(len(types)), Slots: slots}, true
+	})
+}
@@ -0,0 +1,171 @@
+package trevor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// monday is a fixed reference time to extract relative dates.
+var monday = time.Date(2024, time.May, 6, 10, 30, 0, 0, time.UTC)
+
+func TestExtractors(t *testing.T) {
+	dates := NewDateExtractor(func() time.Time { return monday })
+	defaults := Extractors(URLs, Emails, dates, Durations, Quantities, Numbers)
+	day := func(d, h, m int) time.Time { return time.Date(2024, time.May, d, h, m, 0, 0, time.UTC) }
+
+	cases := []struct {
+		extractor Extractor
+		text      string
+		entities  Entities
+	}{
+		{Emails, "write to jane.doe+news@example.com.", Entities{
+			{EmailEntity, "jane.doe+news@example.com", 9, 34, "jane.doe+news@example.com"},
+		}},
+		{URLs, "see https://example.com/a?b=c, or www.example.org.", Entities{
+			{URLEntity, "https://example.com/a?b=c", 4, 29, "https://example.com/a?b=c"},
+			{URLEntity, "www.example.org", 34, 49, "www.example.org"},
+		}},
+		{Numbers, "add 3.5 and twelve to v2", Entities{
+			{NumberEntity, "3.5", 4, 7, 3.5},
+			{NumberEntity, "twelve", 12, 18, 12.0},
+		}},
+		{Durations, "set a timer for 10 minutes and half an hour, then an hour", Entities{
+			{DurationEntity, "10 minutes", 16, 26, 10 * time.Minute},
+			{DurationEntity, "half an hour", 31, 43, 30 * time.Minute},
+			{DurationEntity, "an hour", 50, 57, time.Hour},
+		}},
+		{Quantities, "2 kg of flour, 1.5L of milk and 20% off", Entities{
+			{QuantityEntity, "2 kg", 0, 4, Quantity{2, "kg"}},
+			{QuantityEntity, "1.5L", 15, 19, Quantity{1.5, "l"}},
+			{QuantityEntity, "20%", 32, 35, Quantity{20, "%"}},
+		}},
+		{Quantities, "3 m, 2 l and 4 g", nil},
+		{Quantities, "run 5m and 3 meters", Entities{
+			{QuantityEntity, "5m", 4, 6, Quantity{5, "m"}},
+			{QuantityEntity, "3 meters", 11, 19, Quantity{3, "meters"}},
+		}},
+		{Durations, "ah, 5 h or 5h", Entities{{DurationEntity, "5h", 11, 13, 5 * time.Hour}}},
+		{defaults, "wake me at 5 in the morning", Entities{{NumberEntity, "5", 11, 12, 5.0}}},
+		{defaults, "give me 3 in total", Entities{{NumberEntity, "3", 8, 9, 3.0}}},
+		{dates, "tomorrow at 5pm", Entities{{DateEntity, "tomorrow at 5pm", 0, 15, day(7, 17, 0)}}},
+		{dates, "next monday", Entities{{DateEntity, "next monday", 0, 11, day(13, 0, 0)}}},
+		{dates, "on friday", Entities{{DateEntity, "on friday", 0, 9, day(10, 0, 0)}}},
+		{dates, "last sunday", Entities{{DateEntity, "last sunday", 0, 11, day(5, 0, 0)}}},
+		{dates, "in 3 days", Entities{{DateEntity, "in 3 days", 0, 9, day(9, 10, 30)}}},
+		{dates, "2 hours ago", Entities{{DateEntity, "2 hours ago", 0, 11, day(6, 8, 30)}}},
+		{dates, "on 2024-05-20 at 9:15", Entities{{DateEntity, "2024-05-20 at 9:15", 3, 21, day(20, 9, 15)}}},
+		{dates, "at 18:45", Entities{{DateEntity, "at 18:45", 0, 8, day(6, 18, 45)}}},
+		{dates, "on 2024-02-30 at 25pm", nil},
+		{dates, "remind me at 13pm", nil},
+		{dates, "at 0am", nil},
+		{Quantities, "it costs $5, or €3.50 and 4 euros", Entities{
+			{QuantityEntity, "$5", 9, 11, Quantity{5, "$"}},
+			{QuantityEntity, "€3.50", 16, 23, Quantity{3.5, "€"}},
+			{QuantityEntity, "4 euros", 28, 35, Quantity{4, "euros"}},
+		}},
+		{defaults, "it costs $5", Entities{{QuantityEntity, "$5", 9, 11, Quantity{5, "$"}}}},
+		{defaults, "remind me in 10 minutes to send 2 kg to bob@example.com", Entities{
+			{DateEntity, "in 10 minutes", 10, 23, monday.Add(10 * time.Minute)},
+			{QuantityEntity, "2 kg", 32, 36, Quantity{2, "kg"}},
+			{EmailEntity, "bob@example.com", 40, 55, "bob@example.com"},
+		}},
+	}
+
+	for _, c := range cases {
+		if entities := c.extractor.Extract(NewRequest(c.text, nil)); !reflect.DeepEqual(entities, c.entities) {
+			t.Errorf("%q: expected %v, got %v", c.text, c.entities, entities)
+		}
+	}
+}
+
+func TestExtractorsOverlap(t *testing.T) {
+	extractor := Extractors(Numbers, Durations)
+	entities := extractor.Extract(NewRequest("wait 5 minutes or 3", nil))
+	expected := Entities{
+		{DurationEntity, "5 minutes", 5, 14, 5 * time.Minute},
+		{NumberEntity, "3", 18, 19, 3.0},
+	}
+
+	if !reflect.DeepEqual(entities, expected) {
+		t.Errorf("expected %v, got %v", expected, entities)
+	}
+}
+
+func TestEntities(t *testing.T) {
+	entities := Entities{
+		{Type: NumberEntity, Text: "1"},
+		{Type: EmailEntity, Text: "a@b.co"},
+		{Type: NumberEntity, Text: "2"},
+	}
+
+	if numbers := entities.OfType(NumberEntity); len(numbers) != 2 || numbers[1].Text != "2" {
+		t.Errorf("expected 2 numbers, got %v", numbers)
+	}
+
+	if entity, ok := entities.First(EmailEntity); !ok || entity.Text != "a@b.co" {
+		t.Errorf("expected first email, got %v %v", entity, ok)
+	}
+
+	if _, ok := entities.First(URLEntity); ok {
+		t.Errorf("expected no URL")
+	}
+}
+
+type entitiesPlugin struct {
+	indexPlugin
+	entities Entities
+}
+
+func (p *entitiesPlugin) Analyze(req *Request) (Score, interface{}) {
+	p.entities = req.Entities
+	return NewScore(10, true), nil
+}
+
+func TestProcessExtractsEntities(t *testing.T) {
+	var calls int
+	plugin := &entitiesPlugin{indexPlugin: indexPlugin{name: "entities"}}
+	e := NewEngine()
+	e.SetPlugins([]Plugin{plugin})
+	e.SetExtractor(ExtractorFunc(func(req *Request) Entities {
+		calls++
+		return Numbers.Extract(req)
+	}))
+
+	if _, _, err := e.Process(NewRequest("Give me 5", nil)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := Entities{{NumberEntity, "5", 8, 9, 5.0}}
+	if !reflect.DeepEqual(plugin.entities, expected) {
+		t.Errorf("expected plugin to receive entities %v, got %v", expected, plugin.entities)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected entities to be extracted once, extracted %d times", calls)
+	}
+}
+
+func TestHasEntities(t *testing.T) {
+	e := NewEngine()
+	e.SetExtractor(DefaultExtractor)
+	e.SetPlugins([]Plugin{
+		NewIntentPlugin("timer", 1, nil, HasEntities(DurationEntity), Keywords("timer")),
+		NewIntentPlugin("mail", 1, nil, HasEntities(EmailEntity, URLEntity)),
+	})
+
+	name, data, err := e.Process(NewRequest("set a timer for 20 minutes", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := Slots{"duration": "20 minutes"}
+	if name != "timer" || !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected timer plugin to return %v, got %s %v", expected, name, data)
+	}
+
+	match, ok := HasEntities(EmailEntity, URLEntity).Match(&Request{Entities: Entities{{Type: EmailEntity, Text: "a@b.co"}}})
+	if !ok || match.Score != 5 || match.ExactMatch || !reflect.DeepEqual(match.Slots, Slots{"email": "a@b.co"}) {
+		t.Errorf("expected partial match with email slot, got %v %v", match, ok)
+	}
+}
@@ -0,0 +1,3 @@
+module gopkg.in/mvader/trevor.v1
+
+go 1.20
@@ -0,0 +1,58 @@
+package trevor
+
+// EngineInfo describes the components of an engine.
+type EngineInfo struct {
+	// Plugins are the plugins of the engine.
+	Plugins []PluginInfo `json:"plugins"`
+
+	// Services are the services of the engine, in dependency order.
+	Services []ServiceInfo `json:"services"`
+
+	// Middleware is the number of middleware of the engine.
+	Middleware int `json:"middleware"`
+
+	// Pokes are the schedules of the pokes run by the engine.
+	Pokes []PokeInfo `json:"pokes"`
+}
+
+// ServiceInfo describes a service of the engine.
+type ServiceInfo struct {
+	// Name is the name of the service.
+	Name string `json:"name"`
+
+	// Services are the names of the services injected to the service.
+	Services []string `json:"services"`
+}
+
+func (e *engine) Info() EngineInfo {
+	services := e.current().orderedServices()
+	info := EngineInfo{
+		Plugins:    e.Plugins(),
+		Services:   make([]ServiceInfo, len(services)),
+		Middleware: len(e.middleware),
+		Pokes:      e.pokes(),
+	}
+
+	for i, service := range services {
+		info.Services[i] = ServiceInfo{
+			Name:     service.Name(),
+			Services: uniqueNames(serviceDependencies(service)),
+		}
+	}
+
+	return info
+}
+
+// uniqueNames returns the given names without duplicates, keeping their order.
+func uniqueNames(names []string) []string {
+	unique := make([]string, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,149 @@
+package trevor
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// injectTag is the struct tag used to inject services into the fields of plugins and services.
+const injectTag = "trevor"
+
+// ServiceNotFoundError is returned when a service is looked up but there is no service with that name.
+type ServiceNotFoundError struct {
+	// Name is the name of the service.
+	Name string
+}
+
+func (e *ServiceNotFoundError) Error() string {
+	return "service not found: " + e.Name
+}
+
+// ServiceTypeError is returned when a service is not of the expected type.
+type ServiceTypeError struct {
+	// Name is the name of the service.
+	Name string
+
+	// Expected is the expected type.
+	Expected string
+
+	// Actual is the actual type of the service.
+	Actual string
+}
+
+func (e *ServiceTypeError) Error() string {
+	return fmt.Sprintf("service %s is a %s, not a %s", e.Name, e.Actual, e.Expected)
+}
+
+// As returns the given service, injected with the given name, as a T. It is meant
+// to be used in the SetService method of injectable plugins and services.
+func As[T any](name string, service Service) (T, error) {
+	var zero T
+	if service == nil {
+		return zero, &ServiceNotFoundError{Name: name}
+	}
+
+	typed, ok := service.(T)
+	if !ok {
+		return zero, &ServiceTypeError{
+			Name:     name,
+			Expected: reflect.TypeOf(&zero).Elem().String(),
+			Actual:   reflect.TypeOf(service).String(),
+		}
+	}
+
+	return typed, nil
+}
+
+// Lookup returns the service with the given name as a T using the given function
+// to retrieve services, like the one received by middleware.
+func Lookup[T any](getService func(string) Service, name string) (T, error) {
+	return As[T](name, getService(name))
+}
+
+// taggedServices returns the names of the services requested by the fields of the
+// given plugin or service with the trevor struct tag.
+func taggedServices(component interface{}) []string {
+	var names []string
+	forEachTaggedField(component, func(_ reflect.StructField, _ reflect.Value, name string) {
+		names = append(names, name)
+	})
+
+	return names
+}
+
+// InjectFields sets every field of the struct pointed by target with a trevor
+// struct tag to the service with the name in the tag, e.g:
+//
+//	type myPlugin struct {
+//		Cache *CacheService `trevor:"redis_cache"`
+//	}
+//
+// The engine does this automatically for all plugins and services. Fields must be
+// exported and of a type the service can be assigned to.
+func InjectFields(target interface{}, getService func(string) Service) error {
+	return errors.Join(injectFields(target, getService)...)
+}
+
+func injectFields(target interface{}, getService func(string) Service) []error {
+	return taggedFields(target, getService, true)
+}
+
+// taggedFields checks the services can be injected into the tagged fields of target
+// and returns the errors found. The fields are only set if set is true.
+func taggedFields(target interface{}, getService func(string) Service, set bool) []error {
+	var errs []error
+	forEachTaggedField(target, func(field reflect.StructField, value reflect.Value, name string) {
+		service := getService(name)
+		switch {
+		case service == nil:
+			errs = append(errs, &ServiceNotFoundError{Name: name})
+		case !value.CanSet():
+			errs = append(errs, fmt.Errorf("field %s for service %s is not exported", field.Name, name))
+		case !reflect.TypeOf(service).AssignableTo(field.Type):
+			errs = append(errs, &ServiceTypeError{
+				Name:     name,
+				Expected: field.Type.String(),
+				Actual:   reflect.TypeOf(service).String(),
+			})
+		case set:
+			value.Set(reflect.ValueOf(service))
+		}
+	})
+
+	return errs
+}
+
+// fieldErrors returns the errors injecting the services into the tagged fields of the
+// given plugin or service, without injecting them. Missing services are reported apart.
+func (s *snapshot) fieldErrors(component interface{}) []error {
+	var errs []error
+	for _, err := range taggedFields(component, s.getService, false) {
+		if _, ok := err.(*ServiceNotFoundError); !ok {
+			errs = append(errs, fmt.Errorf("can't inject services to %s: %w", componentName(component), err))
+		}
+	}
+
+	return errs
+}
+
+// injectFields injects the services into the tagged fields of the given plugin or
+// service. The fields that can't be injected are reported by Validate.
+func (s *snapshot) injectFields(component interface{}) {
+	injectFields(component, s.getService)
+}
+
+func forEachTaggedField(component interface{}, fn func(reflect.StructField, reflect.Value, string)) {
+	v := reflect.ValueOf(component)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	v = v.Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if name := t.Field(i).Tag.Get(injectTag); name != "" {
+			fn(t.Field(i), v.Field(i), name)
+		}
+	}
+}
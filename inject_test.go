@@ -0,0 +1,101 @@
+package trevor
+
+import (
+	"errors"
+	"testing"
+)
+
+type taggedPlugin struct {
+	indexPlugin
+	Bar   *barService `trevor:"bar"`
+	Foo   Service     `trevor:"foo"`
+	other *fooService
+}
+
+type wrongTypePlugin struct {
+	indexPlugin
+	Bar *fooService `trevor:"bar"`
+}
+
+type taggedService struct {
+	Store *storeService `trevor:"store"`
+}
+
+func (s *taggedService) Name() string {
+	return "tagged"
+}
+
+func (s *taggedService) SetName(string) {
+}
+
+func TestAs(t *testing.T) {
+	bar, err := As[*barService]("bar", &barService{})
+	if err != nil || bar == nil {
+		t.Errorf("expected bar service, got %v", err)
+	}
+
+	_, err = As[*fooService]("bar", &barService{})
+	var typeErr *ServiceTypeError
+	if !errors.As(err, &typeErr) || typeErr.Expected != "*trevor.fooService" || typeErr.Actual != "*trevor.barService" {
+		t.Errorf("expected service type error, got %v", err)
+	}
+
+	_, err = As[*fooService]("foo", nil)
+	var notFound *ServiceNotFoundError
+	if !errors.As(err, &notFound) || notFound.Name != "foo" {
+		t.Errorf("expected service not found error, got %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	e := NewEngine().(*engine)
+	e.SetServices(dummyServices())
+
+	foo, err := Lookup[*fooService](e.current().getService, "foo")
+	if err != nil || foo.Name() != "foo" {
+		t.Errorf("expected foo service, got %v", err)
+	}
+
+	if _, err := Lookup[*fooService](e.current().getService, "unknown"); err == nil {
+		t.Errorf("expected error for unknown service")
+	}
+}
+
+func TestInjectFields(t *testing.T) {
+	e := NewEngine()
+	e.SetServices(append(dummyServices(), &storeService{}, &taggedService{}))
+
+	plugin := &taggedPlugin{indexPlugin: indexPlugin{name: "tagged"}}
+	e.SetPlugins([]Plugin{plugin})
+
+	if err := e.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if plugin.Bar == nil || plugin.Foo == nil || plugin.other != nil {
+		t.Errorf("expected tagged fields to be injected")
+	}
+
+	if e.(*engine).current().services["tagged"].(*taggedService).Store == nil {
+		t.Errorf("expected tagged field of service to be injected")
+	}
+}
+
+func TestInjectFieldsErrors(t *testing.T) {
+	e := NewEngine()
+	e.SetServices([]Service{&barService{}})
+	e.SetPlugins([]Plugin{&wrongTypePlugin{indexPlugin: indexPlugin{name: "wrong"}}})
+
+	var typeErr *ServiceTypeError
+	if err := e.Validate(); !errors.As(err, &typeErr) {
+		t.Errorf("expected service type error, got %v", err)
+	}
+
+	e = NewEngine()
+	e.SetPlugins([]Plugin{&taggedPlugin{indexPlugin: indexPlugin{name: "tagged"}}})
+
+	var missing *MissingServiceError
+	if err := e.Validate(); !errors.As(err, &missing) || missing.Service != "bar" {
+		t.Errorf("expected missing service error, got %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package trevor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Slots are the values extracted from the text of a request by a Rule, by slot name.
+type Slots map[string]string
+
+// Match is the result of a Rule matching the text of a request.
+type Match struct {
+	// Score is the score of the match, between 0 and DefaultMaxScore.
+	Score float64
+
+	// ExactMatch is true if the rule matched the whole text.
+	ExactMatch bool
+
+	// Slots are the values extracted from the text.
+	Slots Slots
+}
+
+// Rule decides whether the text of a request matches an intent.
+type Rule interface {
+	// Match returns the match of the request and whether the rule matched at all.
+	Match(*Request) (Match, bool)
+}
+
+// RuleFunc is an adapter to use ordinary functions as rules.
+type RuleFunc func(*Request) (Match, bool)
+
+// Match calls f(req).
+func (f RuleFunc) Match(req *Request) (Match, bool) {
+	return f(req)
+}
+
+// normalizedText returns the normalized text of the request or, if the engine has no
+// Normalizer, its text normalized with the DefaultNormalizer.
+func normalizedText(req *Request) string {
+	if req.normalized {
+		return req.NormalizedText
+	}
+
+	return DefaultNormalizer.Normalize(req.Text)
+}
+
+// normalizedTokens returns the tokens of the request or, if the engine has no
+// Normalizer, the tokens of its text normalized with the DefaultNormalizer.
+func normalizedTokens(req *Request) []string {
+	if req.normalized {
+		return req.Tokens
+	}
+
+	return DefaultTokenizer.Tokenize(DefaultNormalizer.Normalize(req.Text))
+}
+
+// Phrases returns a Rule that is an exact match when the text is one of the given
+// phrases. The phrases are normalized with the DefaultNormalizer and compared with
+// the normalized text of the request, so case, diacritics, punctuation and spaces do
+// not matter. A custom Normalizer of the engine should apply the DefaultNormalizer too.
+func Phrases(phrases ...string) Rule {
+	normalized := make(map[string]bool, len(phrases))
+	for _, phrase := range phrases {
+		normalized[DefaultNormalizer.Normalize(phrase)] = true
+	}
+
+	return RuleFunc(func(req *Request) (Match, bool) {
+		if normalized[normalizedText(req)] {
+			return Match{Score: DefaultMaxScore, ExactMatch: true, Slots: Slots{}}, true
+		}
+
+		return Match{}, false
+	})
+}
+
+// Keywords returns a Rule that matches the texts with any of the given keywords.
+// The score is proportional to the number of keywords found. The keywords are
+// normalized with the DefaultNormalizer and compared with the tokens of the request,
+// so a keyword of several words, e.g. "new york", is found when its words are together.
+func Keywords(keywords ...string) Rule {
+	normalized := make([][]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		normalized = append(normalized, DefaultTokenizer.Tokenize(DefaultNormalizer.Normalize(keyword)))
+	}
+
+	return RuleFunc(func(req *Request) (Match, bool) {
+		tokens := normalizedTokens(req)
+
+		var found int
+		for _, keyword := range normalized {
+			if containsTokens(tokens, keyword) {
+				found++
+			}
+		}
+
+		if found == 0 {
+			return Match{}, false
+		}
+
+		return Match{Score: DefaultMaxScore * float64(found) / float64(len(normalized)), Slots: Slots{}}, true
+	})
+}
+
+// containsTokens reports whether tokens contains all the given words one after the other.
+func containsTokens(tokens, words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+
+	for i := 0; i+len(words) <= len(tokens); i++ {
+		match := true
+		for j, word := range words {
+			if tokens[i+j] != word {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Regexp returns a Rule that matches the texts matching the given regular expression.
+// It is an exact match if the expression matches the whole text, otherwise the score
+// is proportional to the part of the text matched. Named groups are extracted as slots.
+// It matches the original text of the request, so slots keep their case and punctuation.
+func Regexp(expr string) (Rule, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return regexpRule(re), nil
+}
+
+// MustRegexp is like Regexp but panics if the expression can't be compiled.
+// It is meant to be used with constant expressions.
+func MustRegexp(expr string) Rule {
+	return mustRule(Regexp(expr))
+}
+
+func regexpRule(re *regexp.Regexp) Rule {
+	return RuleFunc(func(req *Request) (Match, bool) {
+		text := strings.TrimSpace(req.Text)
+		loc := re.FindStringSubmatchIndex(text)
+		if loc == nil {
+			return Match{}, false
+		}
+
+		slots := Slots{}
+		for i, name := range re.SubexpNames() {
+			if name != "" && loc[2*i] >= 0 {
+				slots[name] = strings.TrimSpace(text[loc[2*i]:loc[2*i+1]])
+			}
+		}
+
+		length := len(text)
+		if length == 0 {
+			length = 1
+		}
+
+		return Match{
+			Score:      DefaultMaxScore * float64(loc[1]-loc[0]) / float64(length),
+			ExactMatch: loc[0] == 0 && loc[1] == len(text),
+			Slots:      slots,
+		}, true
+	})
+}
+
+// RepeatedSlotError is the error returned by Template when a slot is repeated in the template.
+type RepeatedSlotError struct {
+	// Template is the template.
+	Template string
+
+	// Slot is the name of the repeated slot.
+	Slot string
+}
+
+func (e *RepeatedSlotError) Error() string {
+	return fmt.Sprintf("slot %s is repeated in template %q", e.Slot, e.Template)
+}
+
+// templateSlot matches the slots of a template, e.g. {song}.
+var templateSlot = regexp.MustCompile(`\{(\w+)\}`)
+
+// Template returns a Rule that is an exact match when the text follows the given
+// template and extracts its slots, e.g. "play {song} by {artist}" matches "Play Let
+// It Be by The Beatles" with the slots song "Let It Be" and artist "The Beatles".
+// Case, extra spaces and trailing punctuation, of both the text and the template, are
+// ignored. A RepeatedSlotError is returned if a slot is repeated in the template.
+func Template(template string) (Rule, error) {
+	template = strings.TrimSpace(template)
+	var (
+		expr = `(?i)^\s*`
+		seen = map[string]bool{}
+		last = 0
+	)
+
+	for _, loc := range templateSlot.FindAllStringSubmatchIndex(template, -1) {
+		name := template[loc[2]:loc[3]]
+		if seen[name] {
+			return nil, &RepeatedSlotError{Template: template, Slot: name}
+		}
+		seen[name] = true
+
+		expr += templateLiteral(template[last:loc[0]]) + `(?P<` + name + `>.+?)`
+		last = loc[1]
+	}
+
+	end := strings.TrimRightFunc(template[last:], func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	expr += templateLiteral(end) + `[\s[:punct:]]*$`
+	return Regexp(expr)
+}
+
+// MustTemplate is like Template but panics if a slot is repeated in the template.
+// It is meant to be used with constant templates.
+func MustTemplate(template string) Rule {
+	return mustRule(Template(template))
+}
+
+func mustRule(rule Rule, err error) Rule {
+	if err != nil {
+		panic(err)
+	}
+
+	return rule
+}
+
+// templateLiteral returns the expression matching the given literal part of a template
+// with any amount of spaces between its words.
+func templateLiteral(literal string) string {
+	words := strings.Fields(literal)
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+
+	expr := strings.Join(words, `\s+`)
+	if strings.TrimLeftFunc(literal, unicode.IsSpace) != literal {
+		expr = `\s+` + expr
+	}
+
+	if len(words) > 0 && strings.TrimRightFunc(literal, unicode.IsSpace) != literal {
+		expr += `\s+`
+	}
+
+	return expr
+}
+
+// IntentHandler processes a request matched by an IntentPlugin with the slots extracted.
+type IntentHandler func(req *Request, slots Slots) (interface{}, error)
+
+// IntentPlugin is a plugin that scores the requests with a set of rules. The best match
+// of all the rules is used as the score of the plugin and its slots are passed to the
+// handler as metadata. It can be embedded into other plugins to override its methods.
+type IntentPlugin struct {
+	name       string
+	precedence int
+	rules      []Rule
+	handler    IntentHandler
+}
+
+// NewIntentPlugin creates a new IntentPlugin with the given name, precedence and rules that
+// processes the requests with the given handler. If the handler is nil, the slots are returned
+// as the data of the plugin.
+func NewIntentPlugin(name string, precedence int, handler IntentHandler, rules ...Rule) *IntentPlugin {
+	return &IntentPlugin{
+		name:       name,
+		precedence: precedence,
+		rules:      rules,
+		handler:    handler,
+	}
+}
+
+// Name returns the name of the plugin.
+func (p *IntentPlugin) Name() string {
+	return p.name
+}
+
+// Precedence returns the precedence of the plugin.
+func (p *IntentPlugin) Precedence() int {
+	return p.precedence
+}
+
+// Analyze returns the best match of all rules, exact matches first, with its slots as metadata.
+func (p *IntentPlugin) Analyze(req *Request) (Score, interface{}) {
+	var (
+		best    Match
+		matched bool
+	)
+
+	for _, rule := range p.rules {
+		match, ok := rule.Match(req)
+		if !ok {
+			continue
+		}
+
+		if !matched || (match.ExactMatch && !best.ExactMatch) ||
+			(match.ExactMatch == best.ExactMatch && match.Score > best.Score) {
+			best, matched = match, true
+		}
+	}
+
+	if !matched {
+		return NewScore(0, false), Slots{}
+	}
+
+	return NewScore(best.Score, best.ExactMatch), best.Slots
+}
+
+// Process calls the handler of the plugin with the slots returned by Analyze.
+func (p *IntentPlugin) Process(req *Request, metadata interface{}) (interface{}, error) {
+	slots, _ := metadata.(Slots)
+	if slots == nil {
+		slots = Slots{}
+	}
+
+	if p.handler == nil {
+		return slots, nil
+	}
+
+	return p.handler(req, slots)
+}
@@ -0,0 +1,142 @@
+package trevor
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	cases := []struct {
+		name    string
+		rule    Rule
+		text    string
+		matched bool
+		match   Match
+	}{
+		{"phrase", Phrases("How are you?", "what's up"), "  HOW ARE   you", true, Match{Score: 10, ExactMatch: true, Slots: Slots{}}},
+		{"phrase diacritics", Phrases("qué tal"), "Que tal!", true, Match{Score: 10, ExactMatch: true, Slots: Slots{}}},
+		{"phrase no match", Phrases("how are you"), "how are you doing", false, Match{}},
+		{"keywords", Keywords("play", "music", "song", "Canción"), "play a cancion", true, Match{Score: 5, Slots: Slots{}}},
+		{"keywords no match", Keywords("play", "music"), "stop", false, Match{}},
+		{"keywords several words", Keywords("New York", "weather"), "weather in new  York?", true, Match{Score: 10, Slots: Slots{}}},
+		{"keywords words apart", Keywords("new york"), "a new day in york", false, Match{}},
+		{"regexp exact", MustRegexp(`^set a timer for (?P<minutes>\d+) minutes$`), "set a timer for 10 minutes", true, Match{Score: 10, ExactMatch: true, Slots: Slots{"minutes": "10"}}},
+		{"regexp partial", MustRegexp(`timer`), "set a timer", true, Match{Score: 10 * 5.0 / 11.0, Slots: Slots{}}},
+		{"regexp no match", MustRegexp(`timer`), "set an alarm", false, Match{}},
+		{"template", MustTemplate("play {song} by {artist}"), "Play  Let It Be by The Beatles!", true, Match{Score: 10, ExactMatch: true, Slots: Slots{"song": "Let It Be", "artist": "The Beatles"}}},
+		{"template adjacent slots", MustTemplate("{verb} {object}"), "open door", true, Match{Score: 10, ExactMatch: true, Slots: Slots{"verb": "open", "object": "door"}}},
+		{"template literal", MustTemplate("stop"), "Stop.", true, Match{Score: 10, ExactMatch: true, Slots: Slots{}}},
+		{"template punctuation", MustTemplate("what is {q}?"), "what is love", true, Match{Score: 10, ExactMatch: true, Slots: Slots{"q": "love"}}},
+		{"template literal punctuation", MustTemplate("stop!"), "stop", true, Match{Score: 10, ExactMatch: true, Slots: Slots{}}},
+		{"template no match", MustTemplate("play {song} by {artist}"), "play Let It Be", false, Match{}},
+	}
+
+	for _, c := range cases {
+		match, ok := c.rule.Match(NewRequest(c.text, nil))
+		if ok != c.matched || !reflect.DeepEqual(match, c.match) {
+			t.Errorf("%s: expected %v %v, got %v %v", c.name, c.matched, c.match, ok, match)
+		}
+	}
+}
+
+func TestTemplateRepeatedSlot(t *testing.T) {
+	_, err := Template("from {city} to {city}")
+	var repeated *RepeatedSlotError
+	if !errors.As(err, &repeated) || repeated.Slot != "city" {
+		t.Errorf("expected a RepeatedSlotError for slot city, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected MustTemplate to panic")
+		}
+	}()
+
+	MustTemplate("from {city} to {city}")
+}
+
+func TestRegexpInvalid(t *testing.T) {
+	if _, err := Regexp(`(unclosed`); err == nil {
+		t.Errorf("expected an error compiling an invalid expression")
+	}
+}
+
+func TestIntentPlugin(t *testing.T) {
+	plugin := NewIntentPlugin("music", 2, func(req *Request, slots Slots) (interface{}, error) {
+		return "playing " + slots["song"], nil
+	},
+		Keywords("play", "music"),
+		MustTemplate("play {song}"),
+	)
+
+	cases := []struct {
+		text       string
+		score      float64
+		exactMatch bool
+		data       interface{}
+	}{
+		{"play Yesterday", 10, true, "playing Yesterday"},
+		{"I like music", 5, false, "playing "},
+		{"stop", 0, false, "playing "},
+	}
+
+	for _, c := range cases {
+		req := NewRequest(c.text, nil)
+		score, metadata := plugin.Analyze(req)
+		if score.Score() != c.score || score.IsExactMatch() != c.exactMatch {
+			t.Errorf("%q: expected score %g, %v, got %g, %v", c.text, c.score, c.exactMatch, score.Score(), score.IsExactMatch())
+		}
+
+		if data, err := plugin.Process(req, metadata); err != nil || data != c.data {
+			t.Errorf("%q: expected data %v, got %v, %v", c.text, c.data, data, err)
+		}
+	}
+}
+
+func TestIntentPluginEngine(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{
+		NewIntentPlugin("music", 1, nil, MustTemplate("play {song} by {artist}")),
+		NewIntentPlugin("weather", 1, nil, Keywords("weather", "rain")),
+	})
+
+	name, data, err := e.Process(NewRequest("play Yellow by Coldplay", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := Slots{"song": "Yellow", "artist": "Coldplay"}
+	if name != "music" || !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected music plugin to return %v, got %s %v", expected, name, data)
+	}
+}
+
+func TestRulesUseEngineNormalization(t *testing.T) {
+	var calls int
+	synonyms := NormalizerFunc(func(text string) string {
+		calls++
+		return strings.ReplaceAll(text, "tune", "song")
+	})
+
+	e := NewEngine()
+	e.SetNormalizer(Normalizers(DefaultNormalizer, synonyms))
+	e.SetPlugins([]Plugin{
+		NewIntentPlugin("music", 1, nil, Keywords("song"), Phrases("play a song")),
+	})
+
+	req := NewRequest("Play a TUNE!", nil)
+	name, _, err := e.Process(req)
+	if err != nil || name != "music" {
+		t.Fatalf("expected music plugin to process the request, got %s and %v", name, err)
+	}
+
+	if score, _ := e.(*engine).current().plugins[0].Analyze(req); !score.IsExactMatch() {
+		t.Errorf("expected phrase to match the normalized text")
+	}
+
+	if calls != 1 {
+		t.Errorf("expected text to be normalized once by the engine, normalized %d times", calls)
+	}
+}
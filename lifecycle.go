@@ -0,0 +1,324 @@
+package trevor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrEngineShutdown is returned when a request is processed by an engine that has been shut down.
+var ErrEngineShutdown = errors.New("the engine has been shut down")
+
+// Initializer is a plugin or service that needs to be initialized before
+// the engine starts processing requests, e.g. to open an index or warm a cache.
+type Initializer interface {
+	// Init initializes the component. It is called after all services have
+	// been injected and after the services the component depends on have
+	// been initialized.
+	Init() error
+}
+
+// Finalizer is a plugin or service that needs to release its resources
+// when the engine is shut down.
+type Finalizer interface {
+	// Finalize finalizes the component. It is called before the services the
+	// component depends on are finalized and before its Close method, if any.
+	Finalize() error
+}
+
+// lifecycle keeps track of the requests being processed and the poke workers
+// running so the engine can be shut down gracefully.
+type lifecycle struct {
+	mu          sync.Mutex
+	closed      bool
+	inFlight    sync.WaitGroup
+	pokers      sync.WaitGroup
+	stopPokes   context.CancelFunc
+	pokeCtx     context.Context
+	closeOnce   sync.Once
+	closeError  error
+	initOnce    sync.Once
+	initError   error
+	initialized bool
+	schedules   []*pokeSchedule
+	reloadMu    sync.Mutex
+
+	// retiring are the snapshots replaced by Reload waiting for their requests
+	// to finish to close the components no longer used.
+	retiring     sync.WaitGroup
+	retireErrors []error
+}
+
+// begin marks the start of a request and reports whether it can be processed.
+func (l *lifecycle) begin() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return false
+	}
+
+	l.inFlight.Add(1)
+	return true
+}
+
+// end marks the end of a request.
+func (l *lifecycle) end() {
+	l.inFlight.Done()
+}
+
+// pokeContext returns the context poke workers run in, which is done once the engine is shut down.
+func (l *lifecycle) pokeContext() context.Context {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pokeCtx == nil {
+		l.pokeCtx, l.stopPokes = context.WithCancel(context.Background())
+		if l.closed {
+			l.stopPokes()
+		}
+	}
+
+	return l.pokeCtx
+}
+
+// drain stops accepting requests and waits until all requests being processed have finished.
+func (l *lifecycle) drain(ctx context.Context) error {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		l.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stopPokeWorkers stops all poke workers and waits for them to finish.
+func (l *lifecycle) stopPokeWorkers() {
+	l.mu.Lock()
+	if l.stopPokes != nil {
+		l.stopPokes()
+	}
+	l.mu.Unlock()
+
+	l.pokers.Wait()
+}
+
+// restartPokeWorkers stops all poke workers so new ones can be started and
+// reports whether there were pokes scheduled.
+func (l *lifecycle) restartPokeWorkers() bool {
+	l.mu.Lock()
+	scheduled := l.pokeCtx != nil && !l.closed
+	if l.stopPokes != nil {
+		l.stopPokes()
+	}
+	l.pokeCtx, l.stopPokes = nil, nil
+	l.schedules = nil
+	l.mu.Unlock()
+
+	l.pokers.Wait()
+	return scheduled
+}
+
+// addPokeSchedule returns a new schedule to keep track of the pokes of the given Pokable.
+func (l *lifecycle) addPokeSchedule(pokable Pokable) *pokeSchedule {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	schedule := newPokeSchedule(pokable)
+	l.schedules = append(l.schedules, schedule)
+	return schedule
+}
+
+// pokes returns the schedules of all the poke workers started.
+func (l *lifecycle) pokes() []PokeInfo {
+	l.mu.Lock()
+	schedules := l.schedules
+	l.mu.Unlock()
+
+	pokes := make([]PokeInfo, len(schedules))
+	for i, schedule := range schedules {
+		pokes[i] = schedule.get()
+	}
+
+	return pokes
+}
+
+// isClosed reports whether the engine is being shut down.
+func (l *lifecycle) isClosed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.closed
+}
+
+// isInitialized reports whether the components of the engine have been initialized successfully.
+func (l *lifecycle) isInitialized() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.initialized
+}
+
+func (e *engine) Init() error {
+	e.initOnce.Do(func() {
+		e.reloadMu.Lock()
+		defer e.reloadMu.Unlock()
+
+		s := e.current()
+		if e.initError = e.validate(s); e.initError == nil {
+			e.initError = initComponents(s.initOrder())
+		}
+
+		e.mu.Lock()
+		e.initialized = e.initError == nil
+		e.mu.Unlock()
+	})
+
+	return e.initError
+}
+
+func (e *engine) Reload(plugins []Plugin, services []Service) error {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	if e.isClosed() {
+		return ErrEngineShutdown
+	}
+
+	// Nothing is injected until the new plugins and services are valid, and the
+	// ones kept from the previous snapshot are never injected again because they
+	// are still processing requests.
+	prev := e.current()
+	next := newSnapshot()
+	next.addServices(services)
+	next.addPlugins(plugins)
+	if err := e.validate(next); err != nil {
+		return err
+	}
+
+	if errs := next.changedDependencies(prev); len(errs) > 0 {
+		return &ConfigError{Errors: errs}
+	}
+
+	next.kept = prev.initOrder()
+	if err := next.inject(); err != nil {
+		return err
+	}
+
+	if e.isInitialized() {
+		if err := initComponents(prev.exclude(next.initOrder())); err != nil {
+			return err
+		}
+	}
+
+	e.snapshotMu.Lock()
+	e.active = next
+	e.snapshotMu.Unlock()
+
+	if e.restartPokeWorkers() {
+		e.schedulePokes(next)
+	}
+
+	e.retire(prev, next.exclude(prev.components()))
+	return nil
+}
+
+// retire waits in the background for the requests being processed with the given
+// snapshot to finish and then finalizes and closes the given components.
+func (e *engine) retire(s *snapshot, components []interface{}) {
+	e.retiring.Add(1)
+	go func() {
+		defer e.retiring.Done()
+
+		s.requests.Wait()
+		if err := closeComponents(components); err != nil {
+			e.mu.Lock()
+			e.retireErrors = append(e.retireErrors, err)
+			e.mu.Unlock()
+		}
+	}()
+}
+
+func (e *engine) Shutdown(ctx context.Context) error {
+	err := e.drain(ctx)
+	e.stopPokeWorkers()
+	if err != nil {
+		return err
+	}
+
+	e.closeOnce.Do(func() {
+		e.reloadMu.Lock()
+		defer e.reloadMu.Unlock()
+
+		e.retiring.Wait()
+		e.closeError = errors.Join(append(e.retireErrors, closeComponents(e.current().components()))...)
+	})
+
+	return e.closeError
+}
+
+// initComponents initializes all the given components implementing Initializer
+// in order. If one of them fails the components already initialized are finalized
+// in reverse order and the error is returned.
+func initComponents(components []interface{}) error {
+	for i, c := range components {
+		if initializer, ok := c.(Initializer); ok {
+			if err := initializer.Init(); err != nil {
+				initialized := make([]interface{}, 0, i)
+				for j := i - 1; j >= 0; j-- {
+					initialized = append(initialized, components[j])
+				}
+				closeComponents(initialized)
+
+				return fmt.Errorf("can't initialize %s: %w", componentName(c), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// closeComponents finalizes and closes all the given components implementing
+// Finalizer or io.Closer and returns all the errors found.
+func closeComponents(components []interface{}) error {
+	var errs []error
+	for _, c := range components {
+		if finalizer, ok := c.(Finalizer); ok {
+			if err := finalizer.Finalize(); err != nil {
+				errs = append(errs, fmt.Errorf("can't finalize %s: %w", componentName(c), err))
+			}
+		}
+
+		if closer, ok := c.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("can't close %s: %w", componentName(c), err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// componentName returns a name to identify the given plugin or service.
+func componentName(c interface{}) string {
+	switch c := c.(type) {
+	case Plugin:
+		return "plugin " + c.Name()
+	case Service:
+		return "service " + c.Name()
+	default:
+		return fmt.Sprintf("%T", c)
+	}
+}
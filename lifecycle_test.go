@@ -0,0 +1,299 @@
+package trevor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type closableService struct {
+	name   string
+	closed *[]string
+}
+
+func (s *closableService) Name() string {
+	return s.name
+}
+
+func (s *closableService) SetName(name string) {
+	s.name = name
+}
+
+func (s *closableService) Close() error {
+	*s.closed = append(*s.closed, s.name)
+	return nil
+}
+
+type closableMemoryService struct {
+	memoryService
+	closed *[]string
+}
+
+func (s *closableMemoryService) SetStore(store Service) error {
+	return nil
+}
+
+func (s *closableMemoryService) Close() error {
+	*s.closed = append(*s.closed, s.Name())
+	return errors.New("memory close error")
+}
+
+type closablePlugin struct {
+	indexPlugin
+	closed *[]string
+}
+
+func (p *closablePlugin) Close() error {
+	*p.closed = append(*p.closed, p.Name())
+	return nil
+}
+
+type initService struct {
+	closableService
+	initialized *[]string
+	err         error
+}
+
+func (s *initService) Init() error {
+	*s.initialized = append(*s.initialized, s.name)
+	return s.err
+}
+
+func (s *initService) Finalize() error {
+	*s.closed = append(*s.closed, "finalize "+s.name)
+	return nil
+}
+
+type dependentService struct {
+	initService
+	needs string
+}
+
+func (s *dependentService) NeededStore() string {
+	return s.needs
+}
+
+func (s *dependentService) TokenForRequest(*http.Request) string {
+	return ""
+}
+
+func (s *dependentService) DataForToken(string) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *dependentService) TokenHeader() string {
+	return ""
+}
+
+func (s *dependentService) SetStore(Service) error {
+	return nil
+}
+
+type initPlugin struct {
+	indexPlugin
+	initialized *[]string
+}
+
+func (p *initPlugin) Init() error {
+	*p.initialized = append(*p.initialized, p.name)
+	return nil
+}
+
+type countingPokable struct {
+	pokes int32
+}
+
+func (p *countingPokable) PokeEvery() time.Duration {
+	return 5 * time.Millisecond
+}
+
+func (p *countingPokable) Poke() bool {
+	atomic.AddInt32(&p.pokes, 1)
+	return false
+}
+
+type pokablePlugin struct {
+	indexPlugin
+	countingPokable
+}
+
+func TestShutdownWaitsForRequests(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{&slowPlugin{50 * time.Millisecond}})
+
+	done := make(chan struct{})
+	go func() {
+		if _, _, err := e.Process(NewRequest("how are you?", nil)); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	select {
+	case <-done:
+	default:
+		t.Errorf("expected shutdown to wait for the request being processed")
+	}
+
+	if _, _, err := e.Process(NewRequest("how are you?", nil)); err != ErrEngineShutdown {
+		t.Errorf("expected engine shutdown error, got %v", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{&slowPlugin{50 * time.Millisecond}})
+
+	go e.Process(NewRequest("how are you?", nil))
+	time.Sleep(5 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	if err := e.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+}
+
+func TestShutdownClosesComponents(t *testing.T) {
+	var closed []string
+	e := NewEngine()
+	e.SetServices([]Service{
+		&closableMemoryService{closed: &closed},
+		&closableService{name: "store", closed: &closed},
+		&closableService{name: "other", closed: &closed},
+	})
+	e.SetPlugins([]Plugin{
+		&closablePlugin{indexPlugin{name: "first", precedence: 2}, &closed},
+		&closablePlugin{indexPlugin{name: "second", precedence: 1}, &closed},
+	})
+
+	err := e.Shutdown(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "memory close error") {
+		t.Errorf("expected close error of memory service, got %v", err)
+	}
+
+	expected := "second,first,other,memory,store"
+	if strings.Join(closed, ",") != expected {
+		t.Errorf("expected components to be closed in order %s, got %s", expected, strings.Join(closed, ","))
+	}
+}
+
+func TestShutdownStopsPokes(t *testing.T) {
+	plugin := &pokablePlugin{indexPlugin: indexPlugin{name: "pokable"}}
+	e := NewEngine()
+	e.SetPlugins([]Plugin{plugin})
+	e.SchedulePokes()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	pokes := atomic.LoadInt32(&plugin.pokes)
+	if pokes == 0 {
+		t.Errorf("expected plugin to be poked before shutdown")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt32(&plugin.pokes) != pokes {
+		t.Errorf("expected plugin not to be poked after shutdown")
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	server := NewServer(Config{
+		Plugins: dummyPlugins(),
+		Port:    9104,
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Run()
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if err := <-result; err != nil {
+		t.Errorf("expected run to return no error after shutdown, got %s", err)
+	}
+
+	if _, err := http.Post("http://0.0.0.0:9104/process", "application/json", strings.NewReader(`{"text":"foo"}`)); err == nil {
+		t.Errorf("expected server not to be listening after shutdown")
+	}
+}
+
+func TestInit(t *testing.T) {
+	var initialized, closed []string
+	e := NewEngine()
+	e.SetServices([]Service{
+		&dependentService{initService{closableService{"memory", &closed}, &initialized, nil}, "store"},
+		&initService{closableService{"store", &closed}, &initialized, nil},
+	})
+	e.SetPlugins([]Plugin{&initPlugin{indexPlugin{name: "plugin"}, &initialized}})
+
+	if err := e.Init(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if strings.Join(initialized, ",") != "store,memory,plugin" {
+		t.Errorf("expected components to be initialized in dependency order, got %v", initialized)
+	}
+
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	expected := "finalize memory,memory,finalize store,store"
+	if strings.Join(closed, ",") != expected {
+		t.Errorf("expected components to be finalized in order %s, got %s", expected, strings.Join(closed, ","))
+	}
+}
+
+func TestInitFailure(t *testing.T) {
+	var initialized, closed []string
+	e := NewEngine()
+	e.SetServices([]Service{
+		&initService{closableService{"first", &closed}, &initialized, nil},
+		&initService{closableService{"broken", &closed}, &initialized, errors.New("index not found")},
+		&initService{closableService{"last", &closed}, &initialized, nil},
+	})
+
+	err := e.Init()
+	if err == nil || err.Error() != "can't initialize service broken: index not found" {
+		t.Errorf("expected init error of broken service, got %v", err)
+	}
+
+	if strings.Join(initialized, ",") != "first,broken" {
+		t.Errorf("expected initialization to stop after the failure, got %v", initialized)
+	}
+
+	if strings.Join(closed, ",") != "finalize first,first" {
+		t.Errorf("expected initialized services to be finalized, got %v", closed)
+	}
+}
+
+func TestRunInitFailure(t *testing.T) {
+	var initialized, closed []string
+	server := NewServer(Config{
+		Plugins:  dummyPlugins(),
+		Services: []Service{&initService{closableService{"broken", &closed}, &initialized, errors.New("index not found")}},
+		Port:     9105,
+	})
+
+	if err := server.Run(); err == nil {
+		t.Errorf("expected run to fail")
+	}
+}
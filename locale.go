@@ -0,0 +1,145 @@
+package trevor
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LanguageDetector is a service that detects the language of a text.
+type LanguageDetector interface {
+	Service
+
+	// DetectLanguage returns the language tag of the given text, e.g. "en" or "es-ES".
+	DetectLanguage(text string) (string, error)
+}
+
+// LocalizedPlugin is a plugin that only supports some languages. Requests in other
+// languages are not analyzed by the plugin.
+type LocalizedPlugin interface {
+	Plugin
+
+	// Languages returns the language tags supported by the plugin, e.g. "en" or "es-ES".
+	// A tag without region, like "en", supports all the regions of the language.
+	Languages() []string
+}
+
+// resolveLocale sets the locale of the request if it has none: the preferred language
+// of the Accept-Language header of the HTTP request or, if there is none, the language
+// detected by the language detector of the engine, if any.
+func (e *engine) resolveLocale(s *snapshot, req *Request) {
+	if req.Locale != "" {
+		req.Locale = normalizeLocale(req.Locale)
+		return
+	}
+
+	if req.Request != nil {
+		if languages := acceptedLanguages(req.Request.Header.Get("Accept-Language")); len(languages) > 0 {
+			req.Locale = languages[0]
+			return
+		}
+	}
+
+	if detector, ok := s.getService(e.languageDetector).(LanguageDetector); ok {
+		// the request is processed without locale if the language can't be detected
+		if locale, err := detector.DetectLanguage(req.Text); err == nil {
+			req.Locale = normalizeLocale(locale)
+		}
+	}
+}
+
+// supportedPlugins returns the given plugins supporting the given locale, keeping their order.
+func supportedPlugins(plugins []Plugin, locale string) []Plugin {
+	if locale == "" {
+		return plugins
+	}
+
+	supported := make([]Plugin, 0, len(plugins))
+	for _, plugin := range plugins {
+		if supportsLocale(plugin, locale) {
+			supported = append(supported, plugin)
+		}
+	}
+
+	return supported
+}
+
+// supportsLocale reports whether the plugin supports the given locale. Plugins
+// that do not declare their languages support all of them.
+func supportsLocale(plugin Plugin, locale string) bool {
+	localized, ok := plugin.(LocalizedPlugin)
+	if !ok || len(localized.Languages()) == 0 {
+		return true
+	}
+
+	for _, language := range localized.Languages() {
+		if matchesLocale(normalizeLocale(language), locale) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesLocale reports whether the given language tag supports the locale, which
+// happens if they are the same or one of them is the base language of the other.
+func matchesLocale(language, locale string) bool {
+	language, locale = strings.ToLower(language), strings.ToLower(locale)
+	return language == locale ||
+		strings.HasPrefix(locale, language+"-") ||
+		strings.HasPrefix(language, locale+"-")
+}
+
+// normalizeLocale returns the given locale using hyphens as separator, e.g. "es_ES" becomes "es-ES".
+func normalizeLocale(locale string) string {
+	return strings.Replace(strings.TrimSpace(locale), "_", "-", -1)
+}
+
+// acceptedLanguages returns the languages of the given Accept-Language header
+// sorted by preference.
+func acceptedLanguages(header string) []string {
+	type language struct {
+		tag string
+		q   float64
+	}
+
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		if q > 0 {
+			languages = append(languages, language{normalizeLocale(tag), q})
+		}
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].q > languages[j].q
+	})
+
+	tags := make([]string, len(languages))
+	for i, l := range languages {
+		tags[i] = l.tag
+	}
+
+	return tags
+}
+
+// setContentLanguage sets the Content-Language header of the response if the request has a locale.
+func setContentLanguage(w http.ResponseWriter, req *Request) {
+	if req.Locale != "" {
+		w.Header().Set("Content-Language", req.Locale)
+	}
+}
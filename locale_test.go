@@ -0,0 +1,194 @@
+package trevor
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type localizedPlugin struct {
+	indexPlugin
+	languages []string
+}
+
+func (p *localizedPlugin) Languages() []string {
+	return p.languages
+}
+
+type detectorService struct {
+	language string
+	err      error
+}
+
+func (s *detectorService) Name() string {
+	return "detector"
+}
+
+func (s *detectorService) SetName(string) {}
+
+func (s *detectorService) DetectLanguage(text string) (string, error) {
+	return s.language, s.err
+}
+
+func localizedPlugins() []Plugin {
+	return []Plugin{
+		&localizedPlugin{indexPlugin: indexPlugin{name: "english", score: 9}, languages: []string{"en"}},
+		&localizedPlugin{indexPlugin: indexPlugin{name: "spanish", score: 8}, languages: []string{"es_ES"}},
+		&indexPlugin{name: "any", score: 7},
+	}
+}
+
+func TestAcceptedLanguages(t *testing.T) {
+	cases := []struct {
+		header    string
+		languages []string
+	}{
+		{"", []string{}},
+		{"es-ES", []string{"es-ES"}},
+		{"fr;q=0.5, en-US, de;q=0.8, *;q=0.1", []string{"en-US", "de", "fr"}},
+		{"en;q=0, es_ES;q=invalid, it", []string{"it"}},
+	}
+
+	for _, c := range cases {
+		if languages := acceptedLanguages(c.header); !reflect.DeepEqual(languages, c.languages) {
+			t.Errorf("%q: expected %v, got %v", c.header, c.languages, languages)
+		}
+	}
+}
+
+func TestMatchesLocale(t *testing.T) {
+	cases := []struct {
+		language, locale string
+		matches          bool
+	}{
+		{"en", "en", true},
+		{"en", "en-US", true},
+		{"EN-us", "en", true},
+		{"en-GB", "en-US", false},
+		{"es", "en", false},
+		{"e", "en", false},
+	}
+
+	for _, c := range cases {
+		if matchesLocale(c.language, c.locale) != c.matches {
+			t.Errorf("expected %s matching %s to be %v", c.language, c.locale, c.matches)
+		}
+	}
+}
+
+func TestProcessLocale(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(localizedPlugins())
+
+	cases := []struct {
+		locale string
+		header string
+		plugin string
+	}{
+		{"", "", "english"},
+		{"es_ES", "", "spanish"},
+		{"", "es-ES,en;q=0.5", "spanish"},
+		{"fr", "", "any"},
+	}
+
+	for _, c := range cases {
+		httpReq, _ := http.NewRequest("POST", "/process", nil)
+		if c.header != "" {
+			httpReq.Header.Set("Accept-Language", c.header)
+		}
+
+		req := NewRequest("foo", httpReq)
+		req.Locale = c.locale
+		if name, _, _ := e.Process(req); name != c.plugin {
+			t.Errorf("locale %q, header %q: expected %s to process the request, got %s", c.locale, c.header, c.plugin, name)
+		}
+	}
+}
+
+func TestProcessLanguageDetector(t *testing.T) {
+	detector := &detectorService{language: "es"}
+	e := NewEngine()
+	e.SetServices([]Service{detector})
+	e.SetPlugins(localizedPlugins())
+	e.SetLanguageDetector("detector")
+
+	req := NewRequest("hola", nil)
+	if name, _, _ := e.Process(req); name != "spanish" || req.Locale != "es" {
+		t.Errorf("expected detected locale es to be processed by spanish, got %s with locale %q", name, req.Locale)
+	}
+
+	detector.err = errors.New("unknown language")
+	req = NewRequest("???", nil)
+	if name, _, _ := e.Process(req); name != "english" || req.Locale != "" {
+		t.Errorf("expected request without locale to be processed by english, got %s with locale %q", name, req.Locale)
+	}
+}
+
+func TestValidateLanguageDetector(t *testing.T) {
+	e := NewEngine()
+	e.SetServices([]Service{&fooService{}})
+	e.SetPlugins(dummyPlugins())
+
+	e.SetLanguageDetector("unknown")
+	var missing *MissingServiceError
+	if err := e.Validate(); !errors.As(err, &missing) {
+		t.Errorf("expected a MissingServiceError, got %v", err)
+	}
+
+	e.SetLanguageDetector("foo")
+	var typeErr *ServiceTypeError
+	if err := e.Validate(); !errors.As(err, &typeErr) {
+		t.Errorf("expected a ServiceTypeError, got %v", err)
+	}
+}
+
+func TestHandlerLocale(t *testing.T) {
+	server := NewServer(Config{Plugins: localizedPlugins()})
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/process", "application/json", strings.NewReader(`{"text":"foo","locale":"es_ES"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var result map[string]interface{}
+	json.Unmarshal(body, &result)
+	if result["type"] != "spanish" || result["locale"] != "es-ES" {
+		t.Errorf("expected spanish to process the request in es-ES, got %s", body)
+	}
+
+	if language := resp.Header.Get("Content-Language"); language != "es-ES" {
+		t.Errorf("expected Content-Language es-ES, got %q", language)
+	}
+
+	resp, err = http.Post(ts.URL+"/process", "application/json", strings.NewReader(`{"text":"foo","locale":1}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid locale, got %d", resp.StatusCode)
+	}
+}
+
+func TestNoMatchPluginUnsupportedLocale(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(localizedPlugins())
+	e.SetMinScore(10)
+	e.SetNoMatchPlugin("english")
+
+	req := NewRequest("foo", nil)
+	req.Locale = "es"
+	if _, _, err := e.Process(req); !errors.As(err, new(*NoMatchError)) {
+		t.Errorf("expected a NoMatchError when the no match plugin does not support the locale, got %v", err)
+	}
+}
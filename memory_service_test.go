@@ -162,6 +162,6 @@ func TestMemoryService(t *testing.T) {
 func assertRememberRequest(token, expectedData, expectedToken string, t *testing.T) {
 	data, token := makeRequestWithHeader(token)
 	if data != expectedData || token != expectedToken {
-		t.Errorf("expecting data '%s', got '%s'. Expecting token '%a', got '%s'", expectedData, data, expectedToken, token)
+		t.Errorf("expecting data '%s', got '%s'. Expecting token '%s', got '%s'", expectedData, data, expectedToken, token)
 	}
 }
@@ -0,0 +1,170 @@
+package trevor
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalizer transforms the text of the requests before they are analyzed, e.g.
+// to lowercase it or remove its punctuation.
+type Normalizer interface {
+	// Normalize returns the normalized text.
+	Normalize(text string) string
+}
+
+// NormalizerFunc is an adapter to use ordinary functions as normalizers.
+type NormalizerFunc func(string) string
+
+// Normalize calls f(text).
+func (f NormalizerFunc) Normalize(text string) string {
+	return f(text)
+}
+
+// Normalizers returns a Normalizer applying all the given normalizers in order.
+func Normalizers(normalizers ...Normalizer) Normalizer {
+	return NormalizerFunc(func(text string) string {
+		for _, n := range normalizers {
+			text = n.Normalize(text)
+		}
+
+		return text
+	})
+}
+
+// Lowercase is a Normalizer that lowercases the text.
+var Lowercase Normalizer = NormalizerFunc(strings.ToLower)
+
+// CollapseSpaces is a Normalizer that trims the text and replaces every sequence
+// of whitespace with a single space.
+var CollapseSpaces Normalizer = NormalizerFunc(func(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+})
+
+// StripPunctuation is a Normalizer that replaces punctuation and symbols with spaces,
+// except the ones inside words like apostrophes or hyphens, e.g. "don't" or "e-mail".
+var StripPunctuation Normalizer = NormalizerFunc(func(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	b.Grow(len(text))
+	for i, r := range runes {
+		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			if isJoiner(r) && i > 0 && i < len(runes)-1 && isWordRune(runes[i-1]) && isWordRune(runes[i+1]) {
+				b.WriteRune(r)
+			} else {
+				b.WriteRune(' ')
+			}
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+})
+
+// FoldDiacritics is a Normalizer that removes the diacritics of latin letters, e.g. "canción"
+// becomes "cancion", and expands ligatures like "æ" or "ß". Combining marks are removed too.
+var FoldDiacritics Normalizer = NormalizerFunc(func(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		if folded, ok := diacritics[r]; ok {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+})
+
+// DefaultNormalizer folds diacritics, lowercases the text, strips its punctuation and collapses its spaces.
+var DefaultNormalizer = Normalizers(FoldDiacritics, Lowercase, StripPunctuation, CollapseSpaces)
+
+// Tokenizer splits the normalized text of the requests into tokens.
+type Tokenizer interface {
+	// Tokenize returns the tokens of the text.
+	Tokenize(text string) []string
+}
+
+// TokenizerFunc is an adapter to use ordinary functions as tokenizers.
+type TokenizerFunc func(string) []string
+
+// Tokenize calls f(text).
+func (f TokenizerFunc) Tokenize(text string) []string {
+	return f(text)
+}
+
+// DefaultTokenizer splits the text into words, that is, sequences of letters and
+// numbers, keeping the apostrophes and hyphens inside words.
+var DefaultTokenizer Tokenizer = TokenizerFunc(func(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !isWordRune(r) && !isJoiner(r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if token := strings.TrimFunc(field, isJoiner); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens
+})
+
+// isJoiner reports whether the rune can join two words, like apostrophes and hyphens.
+func isJoiner(r rune) bool {
+	return r == '\'' || r == '’' || r == '-'
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+}
+
+// diacritics maps the latin letters with diacritics and ligatures to their folded form.
+var diacritics = map[rune]string{
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Ā': "A", 'Ă': "A", 'Ą': "A",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'Æ': "AE", 'æ': "ae",
+	'Ç': "C", 'Ć': "C", 'Ĉ': "C", 'Ċ': "C", 'Č': "C",
+	'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c",
+	'Ď': "D", 'Đ': "D", 'Ð': "D",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ē': "E", 'Ĕ': "E", 'Ė': "E", 'Ę': "E", 'Ě': "E",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'Ĝ': "G", 'Ğ': "G", 'Ġ': "G", 'Ģ': "G",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g",
+	'Ĥ': "H", 'Ħ': "H",
+	'ĥ': "h", 'ħ': "h",
+	'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I", 'Ĩ': "I", 'Ī': "I", 'Ĭ': "I", 'Į': "I", 'İ': "I",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'Ĳ': "IJ", 'ĳ': "ij",
+	'Ĵ': "J", 'ĵ': "j",
+	'Ķ': "K", 'ķ': "k",
+	'Ĺ': "L", 'Ļ': "L", 'Ľ': "L", 'Ŀ': "L", 'Ł': "L",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l",
+	'Ñ': "N", 'Ń': "N", 'Ņ': "N", 'Ň': "N",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O", 'Ō': "O", 'Ŏ': "O", 'Ő': "O",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o",
+	'Œ': "OE", 'œ': "oe",
+	'Ŕ': "R", 'Ŗ': "R", 'Ř': "R",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r",
+	'Ś': "S", 'Ŝ': "S", 'Ş': "S", 'Š': "S",
+	'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s",
+	'ß': "ss",
+	'Ţ': "T", 'Ť': "T", 'Ŧ': "T",
+	'ţ': "t", 'ť': "t", 'ŧ': "t",
+	'Þ': "TH", 'þ': "th",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ũ': "U", 'Ū': "U", 'Ŭ': "U", 'Ů': "U", 'Ű': "U", 'Ų': "U",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'Ŵ': "W", 'ŵ': "w",
+	'Ý': "Y", 'Ŷ': "Y", 'Ÿ': "Y",
+	'ý': "y", 'ÿ': "y", 'ŷ': "y",
+	'Ź': "Z", 'Ż': "Z", 'Ž': "Z",
+	'ź': "z", 'ż': "z", 'ž': "z",
+}
@@ -0,0 +1,101 @@
+package trevor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizers(t *testing.T) {
+	cases := []struct {
+		normalizer Normalizer
+		input      string
+		expected   string
+	}{
+		{Lowercase, "Play SOME Music", "play some music"},
+		{CollapseSpaces, "  play \t some\n music ", "play some music"},
+		{StripPunctuation, "Hey! Don't stop the e-mail, -please-.", "Hey  Don't stop the e-mail   please  "},
+		{FoldDiacritics, "Canción Ñandú straße Œuvre", "Cancion Nandu strasse OEuvre"},
+		{FoldDiacritics, "café", "cafe"},
+		{DefaultNormalizer, "  ¿Qué   TAL, Señor?  ", "que tal senor"},
+		{Normalizers(), "Untouched", "Untouched"},
+	}
+
+	for _, c := range cases {
+		if result := c.normalizer.Normalize(c.input); result != c.expected {
+			t.Errorf("%q: expected %q, got %q", c.input, c.expected, result)
+		}
+	}
+}
+
+func TestDefaultTokenizer(t *testing.T) {
+	cases := []struct {
+		input  string
+		tokens []string
+	}{
+		{"", []string{}},
+		{"play some music", []string{"play", "some", "music"}},
+		{"don't stop, e-mail me at 10!", []string{"don't", "stop", "e-mail", "me", "at", "10"}},
+		{"-- 'quoted' --", []string{"quoted"}},
+	}
+
+	for _, c := range cases {
+		if tokens := DefaultTokenizer.Tokenize(c.input); !reflect.DeepEqual(tokens, c.tokens) {
+			t.Errorf("%q: expected %v, got %v", c.input, c.tokens, tokens)
+		}
+	}
+}
+
+type normalizedPlugin struct {
+	indexPlugin
+	normalized string
+	tokens     []string
+}
+
+func (p *normalizedPlugin) Analyze(req *Request) (Score, interface{}) {
+	p.normalized, p.tokens = req.NormalizedText, req.Tokens
+	return NewScore(10, true), nil
+}
+
+func TestProcessNormalizes(t *testing.T) {
+	var calls int
+	plugin := &normalizedPlugin{indexPlugin: indexPlugin{name: "normalized"}}
+	e := NewEngine()
+	e.SetPlugins([]Plugin{plugin})
+	e.SetNormalizer(Normalizers(NormalizerFunc(func(text string) string {
+		calls++
+		return text
+	}), DefaultNormalizer))
+	e.SetTokenizer(TokenizerFunc(strings.Fields))
+
+	req := NewRequest("¡Pon Música!", nil)
+	if _, _, err := e.Process(req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if req.Text != "¡Pon Música!" {
+		t.Errorf("expected original text to be kept, got %q", req.Text)
+	}
+
+	if plugin.normalized != "pon musica" || !reflect.DeepEqual(plugin.tokens, []string{"pon", "musica"}) {
+		t.Errorf("expected plugin to receive normalized text and tokens, got %q and %v", plugin.normalized, plugin.tokens)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected text to be normalized once, normalized %d times", calls)
+	}
+}
+
+func TestProcessWithoutNormalizer(t *testing.T) {
+	plugin := &normalizedPlugin{indexPlugin: indexPlugin{name: "normalized"}}
+	e := NewEngine()
+	e.SetPlugins([]Plugin{plugin})
+
+	if _, _, err := e.Process(NewRequest("Play Music", nil)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if plugin.normalized != "Play Music" || !reflect.DeepEqual(plugin.tokens, []string{"Play", "Music"}) {
+		t.Errorf("expected text to be tokenized without normalizing, got %q and %v", plugin.normalized, plugin.tokens)
+	}
+}
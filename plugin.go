@@ -1,6 +1,33 @@
 package trevor
 
-import "sort"
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrCannotHandle can be returned by the Process method of a plugin when it can
+// not handle the request, so the engine tries with the next best candidate.
+var ErrCannotHandle = errors.New("plugin can not handle the request")
+
+// PluginError is an error returned by a plugin while processing a request.
+type PluginError struct {
+	// Plugin is the name of the plugin that failed.
+	Plugin string
+
+	// Err is the error returned by the plugin.
+	Err error
+}
+
+func (e PluginError) Error() string {
+	return e.Plugin + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error returned by the plugin.
+func (e PluginError) Unwrap() error {
+	return e.Err
+}
 
 // Plugin defines the base functionality that a trevor plugin should have
 type Plugin interface {
@@ -18,6 +45,28 @@ type Plugin interface {
 	Precedence() int
 }
 
+// ContextPlugin is a plugin that is aware of the context of the request and
+// stops working as soon as the context is done. Analyze and Process are still
+// needed for code not aware of contexts, they can just call their context
+// counterparts with the request context.
+type ContextPlugin interface {
+	Plugin
+
+	// AnalyzeContext works like Analyze but returns an error if the analysis
+	// could not be completed, e.g. because the context is done.
+	AnalyzeContext(context.Context, *Request) (Score, interface{}, error)
+
+	// ProcessContext works like Process but must return as soon as the context is done.
+	ProcessContext(context.Context, *Request, interface{}) (interface{}, error)
+}
+
+// TimeoutPlugin is a plugin that defines its own deadline for every call to
+// analyze or process a request, overriding the plugin timeout of the engine.
+type TimeoutPlugin interface {
+	// Timeout returns the maximum time the plugin can spend on a single call.
+	Timeout() time.Duration
+}
+
 // InjectablePlugin is a plugin that requests dependency injection
 type InjectablePlugin interface {
 	// NeededServices returns an array with the name of all needed services.
@@ -27,6 +76,94 @@ type InjectablePlugin interface {
 	SetService(string, Service)
 }
 
+// AsContextPlugin returns the plugin as a ContextPlugin. Plugins that are not
+// context aware are wrapped in an adapter that runs them in their own goroutine
+// and stops waiting for them once the context is done.
+func AsContextPlugin(plugin Plugin) ContextPlugin {
+	if cp, ok := plugin.(ContextPlugin); ok {
+		return cp
+	}
+
+	return &contextPlugin{plugin}
+}
+
+type contextPlugin struct {
+	Plugin
+}
+
+type analyzeOutput struct {
+	score    Score
+	metadata interface{}
+}
+
+type processOutput struct {
+	data interface{}
+	err  error
+}
+
+func (p *contextPlugin) AnalyzeContext(ctx context.Context, req *Request) (Score, interface{}, error) {
+	out := make(chan analyzeOutput, 1)
+	go func() {
+		score, metadata := p.Analyze(req)
+		out <- analyzeOutput{score, metadata}
+	}()
+
+	select {
+	case o := <-out:
+		return o.score, o.metadata, nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+func (p *contextPlugin) ProcessContext(ctx context.Context, req *Request, metadata interface{}) (interface{}, error) {
+	out := make(chan processOutput, 1)
+	go func() {
+		data, err := p.Process(req, metadata)
+		out <- processOutput{data, err}
+	}()
+
+	select {
+	case o := <-out:
+		return o.data, o.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// pluginContext returns the context in which a single call to the plugin
+// will run, bounded by the plugin own timeout or the given default one.
+func pluginContext(ctx context.Context, plugin Plugin, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if tp, ok := plugin.(TimeoutPlugin); ok {
+		timeout = tp.Timeout()
+	}
+
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+func analyzePlugin(ctx context.Context, plugin Plugin, req *Request, timeout time.Duration) (Score, interface{}, error) {
+	ctx, cancel := pluginContext(ctx, plugin, timeout)
+	defer cancel()
+
+	score, metadata, err := AsContextPlugin(plugin).AnalyzeContext(ctx, req)
+	if err == nil && score == nil {
+		score = NewScore(0, false)
+	}
+
+	return score, metadata, err
+}
+
+func processPlugin(ctx context.Context, plugin Plugin, req *Request, metadata interface{}, timeout time.Duration) (interface{}, error) {
+	ctx, cancel := pluginContext(ctx, plugin, timeout)
+	defer cancel()
+
+	return AsContextPlugin(plugin).ProcessContext(ctx, req, metadata)
+}
+
 type byPluginPrecedence []Plugin
 
 func (b byPluginPrecedence) Len() int {
@@ -0,0 +1,108 @@
+package trevor
+
+import "sync"
+
+// PluginInfo describes a plugin of the engine.
+type PluginInfo struct {
+	// Name is the name of the plugin.
+	Name string `json:"name"`
+
+	// Precedence is the precedence of the plugin.
+	Precedence int `json:"precedence"`
+
+	// Enabled reports whether the plugin can process requests.
+	Enabled bool `json:"enabled"`
+
+	// Services are the names of the services injected to the plugin.
+	Services []string `json:"services"`
+}
+
+// DisabledPluginError is the error returned when a request is meant to be processed
+// by a plugin that has been disabled.
+type DisabledPluginError struct {
+	// Name is the name of the plugin.
+	Name string
+}
+
+func (e *DisabledPluginError) Error() string {
+	return "plugin is disabled: " + e.Name
+}
+
+// pluginState keeps the names of the disabled plugins. It does not depend on the
+// snapshot of the engine, so plugins are still disabled after a reload.
+type pluginState struct {
+	mu       sync.RWMutex
+	disabled map[string]bool
+}
+
+func (s *pluginState) isEnabled(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return !s.disabled[name]
+}
+
+func (s *pluginState) setEnabled(name string, enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if enabled {
+		delete(s.disabled, name)
+	} else {
+		if s.disabled == nil {
+			s.disabled = map[string]bool{}
+		}
+		s.disabled[name] = true
+	}
+}
+
+// enabledPlugins returns the given plugins that are enabled, keeping their order.
+func (s *pluginState) enabledPlugins(plugins []Plugin) []Plugin {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.disabled) == 0 {
+		return plugins
+	}
+
+	enabled := make([]Plugin, 0, len(plugins))
+	for _, plugin := range plugins {
+		if !s.disabled[plugin.Name()] {
+			enabled = append(enabled, plugin)
+		}
+	}
+
+	return enabled
+}
+
+func (e *engine) EnablePlugin(name string) error {
+	return e.setPluginEnabled(name, true)
+}
+
+func (e *engine) DisablePlugin(name string) error {
+	return e.setPluginEnabled(name, false)
+}
+
+func (e *engine) setPluginEnabled(name string, enabled bool) error {
+	if _, err := e.current().getPlugin(name); err != nil {
+		return err
+	}
+
+	e.state.setEnabled(name, enabled)
+	return nil
+}
+
+func (e *engine) Plugins() []PluginInfo {
+	plugins := e.current().plugins
+	infos := make([]PluginInfo, len(plugins))
+	for i, plugin := range plugins {
+		infos[i] = PluginInfo{
+			Name:       plugin.Name(),
+			Precedence: plugin.Precedence(),
+			Enabled:    e.state.isEnabled(plugin.Name()),
+			Services:   uniqueNames(pluginDependencies(plugin)),
+		}
+	}
+
+	return infos
+}
@@ -0,0 +1,146 @@
+package trevor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDisablePlugin(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{
+		&indexPlugin{name: "best", score: 9},
+		&indexPlugin{name: "other", score: 5},
+	})
+
+	if err := e.DisablePlugin("best"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if name, _, _ := e.Process(NewRequest("foo", nil)); name != "other" {
+		t.Errorf("expected other to process the request, got %s", name)
+	}
+
+	if err := e.EnablePlugin("best"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if name, _, _ := e.Process(NewRequest("foo", nil)); name != "best" {
+		t.Errorf("expected best to process the request, got %s", name)
+	}
+}
+
+func TestDisablePluginUnknown(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+
+	var unknown *UnknownPluginError
+	if err := e.DisablePlugin("unknown"); !errors.As(err, &unknown) {
+		t.Errorf("expected an UnknownPluginError, got %v", err)
+	}
+}
+
+func TestDisablePluginRouted(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{
+		&indexPlugin{name: "routed", score: 9},
+		&indexPlugin{name: "other", score: 5},
+	})
+	e.SetRouter(RouterFunc(func(*Request) (string, interface{}, error) {
+		return "routed", nil, nil
+	}))
+	e.DisablePlugin("routed")
+
+	if name, _, _ := e.Process(NewRequest("foo", nil)); name != "other" {
+		t.Errorf("expected request to fall back to scoring, got %s", name)
+	}
+}
+
+func TestDisablePluginChosen(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+	e.DisablePlugin("salute")
+
+	req := NewRequest("how are you?", nil)
+	req.Plugin = "salute"
+
+	var disabled *DisabledPluginError
+	if _, _, err := e.Process(req); !errors.As(err, &disabled) {
+		t.Errorf("expected a DisabledPluginError, got %v", err)
+	}
+}
+
+func TestDisablePluginReload(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+	e.DisablePlugin("salute")
+
+	if err := e.Reload(dummyPlugins(), nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []PluginInfo{
+		{Name: "salute", Precedence: 2, Enabled: false, Services: []string{}},
+		{Name: "foo", Precedence: 1, Enabled: true, Services: []string{}},
+	}
+	if plugins := e.Plugins(); !reflect.DeepEqual(plugins, expected) {
+		t.Errorf("expected plugins to be %v, got %v", expected, plugins)
+	}
+}
+
+func TestRunPluginState(t *testing.T) {
+	server := NewServer(Config{
+		Plugins:       dummyPlugins(),
+		Port:          9107,
+		AdminEndpoint: "admin",
+	})
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	time.Sleep(5 * time.Millisecond)
+
+	cases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"POST", "/admin/plugins/salute/disable", http.StatusOK},
+		{"POST", "/admin/plugins/unknown/disable", http.StatusNotFound},
+		{"POST", "/admin/plugins/salute/restart", http.StatusNotFound},
+		{"GET", "/admin/plugins/salute/enable", http.StatusNotFound},
+		{"POST", "/admin/reload", http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, "http://0.0.0.0:9107"+c.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != c.status {
+			t.Errorf("%s %s: expected status %d, got %d", c.method, c.path, c.status, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get("http://0.0.0.0:9107/admin/plugins")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var result struct {
+		Plugins []PluginInfo `json:"plugins"`
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	json.Unmarshal(body, &result)
+
+	if len(result.Plugins) != 2 || result.Plugins[0].Name != "salute" || result.Plugins[0].Enabled {
+		t.Errorf("expected salute to be listed as disabled, got %s", body)
+	}
+}
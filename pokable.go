@@ -1,6 +1,11 @@
 package trevor
 
-import "time"
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
 
 // Pokable is a component (plugin or service) that needs to be poked every X time.
 type Pokable interface {
@@ -39,8 +44,89 @@ func PokableServices(services []Service) []Pokable {
 
 // RunPokeWorker runs a new worker that will run indefinitely poking the Pokable until it tells the worker to stop.
 func RunPokeWorker(pokable Pokable) {
+	RunPokeWorkerContext(context.Background(), pokable)
+}
+
+// RunPokeWorkerContext runs a new worker that will poke the Pokable until it tells the worker to stop or the context is done.
+func RunPokeWorkerContext(ctx context.Context, pokable Pokable) {
+	runPokeWorker(ctx, pokable, nil)
+}
+
+// PokeInfo describes the schedule of a Pokable run by the engine.
+type PokeInfo struct {
+	// Component is the plugin or service poked, e.g. "plugin foo".
+	Component string `json:"component"`
+
+	// Every is the lapse between a poke and another.
+	Every time.Duration `json:"every"`
+
+	// LastRun is the last time the component was poked. It is zero if it has not been poked yet.
+	LastRun time.Time `json:"last_run"`
+
+	// NextRun is the next time the component will be poked. It is zero if it will not be poked anymore.
+	NextRun time.Time `json:"next_run"`
+}
+
+// MarshalJSON encodes the poke info with Every as a string, e.g. "1m30s", like the
+// durations of the config.
+func (p PokeInfo) MarshalJSON() ([]byte, error) {
+	type pokeInfo PokeInfo
+	return json.Marshal(struct {
+		pokeInfo
+		Every string `json:"every"`
+	}{pokeInfo(p), p.Every.String()})
+}
+
+// pokeSchedule keeps track of the pokes of a poke worker.
+type pokeSchedule struct {
+	mu   sync.Mutex
+	info PokeInfo
+}
+
+func newPokeSchedule(pokable Pokable) *pokeSchedule {
+	return &pokeSchedule{info: PokeInfo{Component: componentName(pokable)}}
+}
+
+func (s *pokeSchedule) update(fn func(*PokeInfo)) {
+	if s != nil {
+		s.mu.Lock()
+		fn(&s.info)
+		s.mu.Unlock()
+	}
+}
+
+func (s *pokeSchedule) get() PokeInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.info
+}
+
+// runPokeWorker pokes the Pokable until it tells the worker to stop or the context is done,
+// recording the pokes in the given schedule, if any.
+func runPokeWorker(ctx context.Context, pokable Pokable, schedule *pokeSchedule) {
+	defer schedule.update(func(info *PokeInfo) {
+		info.NextRun = time.Time{}
+	})
+
 	for {
-		time.Sleep(pokable.PokeEvery())
+		every := pokable.PokeEvery()
+		schedule.update(func(info *PokeInfo) {
+			info.Every = every
+			info.NextRun = time.Now().Add(every)
+		})
+
+		timer := time.NewTimer(every)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		schedule.update(func(info *PokeInfo) {
+			info.LastRun = time.Now()
+		})
 
 		if pokable.Poke() {
 			break
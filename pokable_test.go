@@ -1,6 +1,8 @@
 package trevor
 
 import (
+	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -55,3 +57,27 @@ func TestRunPokeWorker(t *testing.T) {
 		t.Errorf("expected service to be poked 5 times but was poked just %d", service.poked)
 	}
 }
+
+func TestRunPokeWorkerContext(t *testing.T) {
+	pokable := &countingPokable{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		RunPokeWorkerContext(ctx, pokable)
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(50 * time.Millisecond):
+		t.Errorf("expected poke worker to stop when the context is done")
+	}
+
+	if atomic.LoadInt32(&pokable.pokes) == 0 {
+		t.Errorf("expected pokable to be poked before the context is done")
+	}
+}
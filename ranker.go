@@ -0,0 +1,103 @@
+package trevor
+
+import "sort"
+
+// Ranker sorts the candidates to process a request from best to worst.
+type Ranker interface {
+	// Rank sorts the given candidates in place, the best candidate first.
+	Rank([]Candidate)
+}
+
+// RankerFunc is an adapter to use ordinary functions as rankers.
+type RankerFunc func([]Candidate)
+
+// Rank calls f(candidates).
+func (f RankerFunc) Rank(candidates []Candidate) {
+	f(candidates)
+}
+
+// DefaultRanker sorts the candidates by exact match, then by score and then by precedence.
+// Candidates that are equal keep their original order.
+var DefaultRanker Ranker = RankerFunc(func(candidates []Candidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return lessCandidate(candidates[i], candidates[j], candidates[i].Score, candidates[j].Score)
+	})
+})
+
+// WeightedRanker returns a Ranker that sorts the candidates by exact match and then by their
+// score multiplied by their precedence, so plugins with a higher precedence need less score to win.
+// Precedences lower than 1 weigh as 1, so their scores are neither zeroed nor inverted.
+func WeightedRanker() Ranker {
+	weight := func(c Candidate) float64 {
+		if c.Precedence < 1 {
+			return c.Score
+		}
+
+		return c.Score * float64(c.Precedence)
+	}
+
+	return RankerFunc(func(candidates []Candidate) {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			return lessCandidate(a, b, weight(a), weight(b))
+		})
+	})
+}
+
+// DefaultMaxScore is the maximum score assumed for a plugin by NormalizedRanker
+// when none is given, which is the top of the recommended range of scores.
+const DefaultMaxScore = 10
+
+// NormalizedRanker returns a Ranker that works like the DefaultRanker but divides
+// the score of every plugin by the maximum score of that plugin, so plugins using
+// different ranges of scores can be compared. Plugins not present in maxScores are
+// assumed to have a maximum score of DefaultMaxScore.
+func NormalizedRanker(maxScores map[string]float64) Ranker {
+	normalize := func(c Candidate) float64 {
+		max, ok := maxScores[c.Plugin]
+		if !ok || max == 0 {
+			max = DefaultMaxScore
+		}
+
+		return c.Score / max
+	}
+
+	return RankerFunc(func(candidates []Candidate) {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			return lessCandidate(a, b, normalize(a), normalize(b))
+		})
+	})
+}
+
+// lessCandidate reports whether a ranks better than b using the given scores
+// for them: exact matches first, then higher scores and then higher precedence.
+func lessCandidate(a, b Candidate, scoreA, scoreB float64) bool {
+	if a.ExactMatch != b.ExactMatch {
+		return a.ExactMatch
+	}
+
+	if scoreA != scoreB {
+		return scoreA > scoreB
+	}
+
+	return a.Precedence > b.Precedence
+}
+
+// rankResults sorts the analysis results using the given ranker.
+func rankResults(results []analysisResult, ranker Ranker) {
+	candidates := make([]Candidate, len(results))
+	for i, result := range results {
+		candidates[i] = result.candidate()
+		candidates[i].index = i
+	}
+
+	ranker.Rank(candidates)
+
+	ranked := make([]analysisResult, len(results))
+	for i, candidate := range candidates {
+		ranked[i] = results[candidate.index]
+	}
+
+	copy(results, ranked)
+}
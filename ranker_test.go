@@ -0,0 +1,86 @@
+package trevor
+
+import "testing"
+
+func rankedPlugins(ranker Ranker, candidates []Candidate) []string {
+	ranker.Rank(candidates)
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		names[i] = c.Plugin
+	}
+
+	return names
+}
+
+func assertRanking(t *testing.T, expected, got []string) {
+	if len(expected) != len(got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	for i := range expected {
+		if expected[i] != got[i] {
+			t.Errorf("expected %s to be at position %d, %s found", expected[i], i, got[i])
+		}
+	}
+}
+
+func TestDefaultRankerIsStable(t *testing.T) {
+	candidates := []Candidate{
+		{Plugin: "a", Score: 1, Precedence: 1},
+		{Plugin: "b", Score: 2, Precedence: 1},
+		{Plugin: "c", Score: 1, Precedence: 1},
+		{Plugin: "d", Score: 1, Precedence: 1, ExactMatch: true},
+		{Plugin: "e", Score: 1, Precedence: 1},
+	}
+
+	assertRanking(t, []string{"d", "b", "a", "c", "e"}, rankedPlugins(DefaultRanker, candidates))
+}
+
+func TestWeightedRanker(t *testing.T) {
+	candidates := []Candidate{
+		{Plugin: "a", Score: 5, Precedence: 1},
+		{Plugin: "b", Score: 3, Precedence: 2},
+		{Plugin: "c", Score: 1, Precedence: 1, ExactMatch: true},
+		{Plugin: "d", Score: 6, Precedence: 1},
+	}
+
+	assertRanking(t, []string{"c", "b", "d", "a"}, rankedPlugins(WeightedRanker(), candidates))
+}
+
+func TestWeightedRankerNonPositivePrecedence(t *testing.T) {
+	candidates := []Candidate{
+		{Plugin: "zero", Score: 2, Precedence: 0},
+		{Plugin: "negative", Score: 8, Precedence: -3},
+		{Plugin: "positive", Score: 3, Precedence: 2},
+	}
+
+	assertRanking(t, []string{"negative", "positive", "zero"}, rankedPlugins(WeightedRanker(), candidates))
+}
+
+func TestNormalizedRanker(t *testing.T) {
+	candidates := []Candidate{
+		{Plugin: "a", Score: 50, Precedence: 1},
+		{Plugin: "b", Score: 8, Precedence: 1},
+		{Plugin: "c", Score: 0.9, Precedence: 1},
+	}
+
+	ranker := NormalizedRanker(map[string]float64{"a": 100, "c": 1})
+	assertRanking(t, []string{"c", "b", "a"}, rankedPlugins(ranker, candidates))
+}
+
+func TestEngineRanker(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins([]Plugin{
+		&indexPlugin{name: "low", score: 5, precedence: 1},
+		&indexPlugin{name: "high", score: 3, precedence: 2},
+	})
+
+	if plugin, _, _ := e.Process(NewRequest("foo", nil)); plugin != "low" {
+		t.Errorf("expected low plugin to be chosen by the default ranker, %s was", plugin)
+	}
+
+	e.SetRanker(WeightedRanker())
+	if plugin, _, _ := e.Process(NewRequest("foo", nil)); plugin != "high" {
+		t.Errorf("expected high plugin to be chosen by the weighted ranker, %s was", plugin)
+	}
+}
@@ -0,0 +1,304 @@
+package trevor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetPluginsReplacesPlugins(t *testing.T) {
+	e := NewEngine().(*engine)
+	e.SetPlugins([]Plugin{&indexPlugin{name: "a"}, &indexPlugin{name: "b"}})
+	e.SetPlugins([]Plugin{&indexPlugin{name: "c"}})
+
+	if _, err := e.current().getPlugin("a"); err == nil {
+		t.Errorf("expected plugin a to be removed")
+	}
+
+	if _, err := e.current().getPlugin("c"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	var initialized, closed []string
+	e := NewEngine()
+	e.SetServices([]Service{&closableService{name: "kept", closed: &closed}, &closableService{name: "old", closed: &closed}})
+	e.SetPlugins([]Plugin{&closablePlugin{indexPlugin: indexPlugin{name: "a", score: 5}, closed: &closed}})
+	if err := e.Init(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	kept := e.(*engine).current().services["kept"]
+	err := e.Reload(
+		[]Plugin{&initPlugin{indexPlugin: indexPlugin{name: "b", score: 5}, initialized: &initialized}},
+		[]Service{kept, &initService{closableService: closableService{name: "new", closed: &closed}, initialized: &initialized}},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(initialized, []string{"new", "b"}) {
+		t.Errorf("expected only new components to be initialized, got %v", initialized)
+	}
+
+	name, _, err := e.Process(NewRequest("foo", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if name != "b" {
+		t.Errorf("expected request to be processed by b, got %s", name)
+	}
+
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{"a", "old", "finalize new", "new", "kept"}
+	if !reflect.DeepEqual(closed, expected) {
+		t.Errorf("expected closed components to be %v, got %v", expected, closed)
+	}
+}
+
+func TestReloadInvalid(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+
+	err := e.Reload([]Plugin{&barPlugin{}}, nil)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected a ConfigError, got %v", err)
+	}
+
+	if name, _, err := e.Process(NewRequest("how are you?", nil)); err != nil || name != "salute" {
+		t.Errorf("expected previous plugins to process the request, got %s, %v", name, err)
+	}
+}
+
+func TestReloadInvalidKeepsInjectedServices(t *testing.T) {
+	oldFoo, newFoo := &fooService{}, &fooService{}
+	plugin := &taggedPlugin{indexPlugin: indexPlugin{name: "p"}}
+	e := NewEngine()
+	e.SetServices([]Service{&barService{}, oldFoo})
+	e.SetPlugins([]Plugin{plugin})
+
+	err := e.Reload([]Plugin{plugin, &indexPlugin{name: "p"}}, []Service{&barService{}, newFoo})
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected a ConfigError, got %v", err)
+	}
+
+	if plugin.Foo != oldFoo {
+		t.Errorf("expected live plugin to keep its services after a failed reload")
+	}
+
+	err = e.Reload([]Plugin{plugin}, []Service{&barService{}, newFoo})
+	var changedErr *ChangedDependencyError
+	if !errors.As(err, &changedErr) || changedErr.Component != "plugin p" || changedErr.Service != "foo" {
+		t.Fatalf("expected a ChangedDependencyError, got %v", err)
+	}
+
+	if plugin.Foo != oldFoo {
+		t.Errorf("expected live plugin not to be injected a different service")
+	}
+
+	fresh := &taggedPlugin{indexPlugin: indexPlugin{name: "p"}}
+	if err := e.Reload([]Plugin{fresh}, []Service{&barService{}, newFoo}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if fresh.Foo != newFoo || plugin.Foo != oldFoo {
+		t.Errorf("expected only the new plugin to be injected the new services")
+	}
+}
+
+// servicePlugin sets its service in SetService and uses it in Analyze, like
+// the injectable plugins in the README.
+type servicePlugin struct {
+	indexPlugin
+	foo *fooService
+}
+
+func (p *servicePlugin) NeededServices() []string {
+	return []string{"foo"}
+}
+
+func (p *servicePlugin) SetService(name string, service Service) {
+	p.foo = service.(*fooService)
+}
+
+func (p *servicePlugin) Analyze(req *Request) (Score, interface{}) {
+	time.Sleep(p.delay)
+	return NewScore(5, false), p.foo.Name()
+}
+
+func TestReloadWhileProcessing(t *testing.T) {
+	foo := &fooService{}
+	plugin := &servicePlugin{indexPlugin: indexPlugin{name: "p", delay: time.Millisecond}}
+	e := NewEngine()
+	e.SetServices([]Service{foo})
+	e.SetPlugins([]Plugin{plugin})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, _, err := e.Process(NewRequest("hi", nil)); err != nil {
+					t.Errorf("unexpected error: %s", err)
+					return
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if err := e.Reload([]Plugin{plugin}, []Service{foo}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
+func TestReloadInitFailure(t *testing.T) {
+	var initialized, closed []string
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+	if err := e.Init(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := e.Reload(dummyPlugins(), []Service{
+		&initService{closableService: closableService{name: "a", closed: &closed}, initialized: &initialized},
+		&initService{closableService: closableService{name: "b", closed: &closed}, initialized: &initialized, err: errors.New("init error")},
+	})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if !reflect.DeepEqual(closed, []string{"finalize a", "a"}) {
+		t.Errorf("expected initialized services to be closed, got %v", closed)
+	}
+
+	if e.(*engine).current().getService("a") != nil {
+		t.Errorf("expected previous services to be kept")
+	}
+}
+
+func TestReloadWaitsForRequests(t *testing.T) {
+	var closed []string
+	e := NewEngine()
+	e.SetPlugins([]Plugin{&closablePlugin{indexPlugin: indexPlugin{name: "a", score: 5, delay: 50 * time.Millisecond}, closed: &closed}})
+
+	done := make(chan string, 1)
+	go func() {
+		name, _, _ := e.Process(NewRequest("foo", nil))
+		done <- name
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := e.Reload([]Plugin{&indexPlugin{name: "b", score: 5}}, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if name, _, _ := e.Process(NewRequest("foo", nil)); name != "b" {
+		t.Errorf("expected new request to be processed by b, got %s", name)
+	}
+
+	if name := <-done; name != "a" {
+		t.Errorf("expected request in flight to be processed by a, got %s", name)
+	}
+
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(closed, []string{"a"}) {
+		t.Errorf("expected a to be closed, got %v", closed)
+	}
+}
+
+func TestReloadAfterShutdown(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := e.Reload(dummyPlugins(), nil); err != ErrEngineShutdown {
+		t.Errorf("expected ErrEngineShutdown, got %v", err)
+	}
+}
+
+func TestRunReload(t *testing.T) {
+	var fail bool
+	server := NewServer(Config{
+		Plugins:       dummyPlugins(),
+		Port:          9106,
+		AdminEndpoint: "admin",
+		Reload: func() ([]Plugin, []Service, error) {
+			if fail {
+				return nil, nil, errors.New("can't load plugins")
+			}
+
+			return []Plugin{&indexPlugin{name: "reloaded", score: 5}}, nil, nil
+		},
+	})
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	time.Sleep(5 * time.Millisecond)
+
+	resp, err := http.Post("http://0.0.0.0:9106/admin/reload", "application/json", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post("http://0.0.0.0:9106/process", "application/json", strings.NewReader(`{"text":"hello"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var result map[string]interface{}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	json.Unmarshal(body, &result)
+	if result["type"] != "reloaded" {
+		t.Errorf("expected request to be processed by the reloaded plugin, got %s", body)
+	}
+
+	fail = true
+	resp, err = http.Post("http://0.0.0.0:9106/admin/reload", "application/json", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", resp.StatusCode)
+	}
+}
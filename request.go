@@ -1,12 +1,27 @@
 package trevor
 
-import "net/http"
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+)
 
 // Request is the context of a request to the trevor engine.
 type Request struct {
 	// Text is the text that came with the request.
 	Text string
 
+	// NormalizedText is the text after applying the Normalizer of the engine, shared by all
+	// plugins. It is the same as Text if the engine has no Normalizer.
+	NormalizedText string
+
+	// Tokens are the words of the normalized text split by the Tokenizer of the engine.
+	Tokens []string
+
+	// Entities are the entities found in the text by the Extractor of the engine, like
+	// dates, numbers or URLs. They are extracted once and shared by all plugins.
+	Entities Entities
+
 	// Request is the current HTTP request.
 	Request *http.Request
 
@@ -15,12 +30,68 @@ type Request struct {
 	// So, if you want the client to have the token you should
 	// change this value in that case on your plugin, service, ...
 	Token string
+
+	// Failed contains the errors of the plugins that were chosen to process
+	// the request but failed to do so before another one answered.
+	Failed []PluginError
+
+	// Plugin is the name of the plugin chosen by the client to process the request.
+	// If it is not empty the request will not be analyzed by any other plugin.
+	Plugin string
+
+	// AllowedPlugins is the list of plugins the client allows to process the request.
+	// If it is empty, all plugins are allowed.
+	AllowedPlugins []string
+
+	// Alternatives are the next best candidates to process the request, if the
+	// engine is configured to return them.
+	Alternatives []Candidate
+
+	// Locale is the language tag of the request, e.g. "en" or "es-ES". If it is empty
+	// the engine takes it from the Accept-Language header of the HTTP request or
+	// detects it with its LanguageDetector, if any.
+	Locale string
+
+	// Attributes are the other fields of the JSON object sent to the endpoint.
+	Attributes Attributes
+
+	// ClientCertificate is the certificate of the client, if the HTTP request
+	// was made over TLS with a client certificate verified by the server.
+	ClientCertificate *x509.Certificate
+
+	// normalized is true if the engine normalized the text with its Normalizer.
+	normalized bool
+
+	ctx context.Context
 }
 
 // NewRequest creates a new request instance.
 func NewRequest(text string, req *http.Request) *Request {
 	return &Request{
-		Text:    text,
-		Request: req,
+		Text:              text,
+		Request:           req,
+		ClientCertificate: clientCertificate(req),
+	}
+}
+
+// NewRequestWithContext creates a new request instance bound to the given context.
+func NewRequestWithContext(ctx context.Context, text string, req *http.Request) *Request {
+	r := NewRequest(text, req)
+	r.ctx = ctx
+	return r
+}
+
+// Context returns the context of the request. If no context was given the
+// context of the HTTP request is used and, if there is no HTTP request either,
+// the background context.
+func (r *Request) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
 	}
+
+	if r.Request != nil {
+		return r.Request.Context()
+	}
+
+	return context.Background()
 }
@@ -0,0 +1,57 @@
+package trevor
+
+import "errors"
+
+// ErrNoRoute is returned by a Router when it can not choose a plugin for a
+// request, so the decision is left to the next router.
+var ErrNoRoute = errors.New("no route for the request")
+
+// Router chooses the plugin that will process a request. If the engine has a
+// router it will be asked first and, if it returns ErrNoRoute, the engine will
+// choose the plugin scoring the request with all plugins.
+type Router interface {
+	// Route returns the name of the plugin that should process the request and
+	// the metadata that will be passed to it.
+	Route(*Request) (string, interface{}, error)
+}
+
+// Analyzer is a function that takes the current request to process and returns the name of the plugin that should process it and metadata.
+type Analyzer func(*Request) (string, interface{})
+
+// RouterFunc is an adapter to use ordinary functions as routers.
+type RouterFunc func(*Request) (string, interface{}, error)
+
+// Route calls f(req).
+func (f RouterFunc) Route(req *Request) (string, interface{}, error) {
+	return f(req)
+}
+
+// Route makes an Analyzer a Router. An empty plugin name means the analyzer
+// could not choose a plugin.
+func (a Analyzer) Route(req *Request) (string, interface{}, error) {
+	name, metadata := a(req)
+	if name == "" {
+		return "", nil, ErrNoRoute
+	}
+
+	return name, metadata, nil
+}
+
+// Routers returns a Router that asks all the given routers in order until one
+// of them chooses a plugin.
+func Routers(routers ...Router) Router {
+	return routerChain(routers)
+}
+
+type routerChain []Router
+
+func (c routerChain) Route(req *Request) (string, interface{}, error) {
+	for _, router := range c {
+		name, metadata, err := router.Route(req)
+		if err != ErrNoRoute {
+			return name, metadata, err
+		}
+	}
+
+	return "", nil, ErrNoRoute
+}
@@ -0,0 +1,85 @@
+package trevor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRouters(t *testing.T) {
+	var calls []string
+	router := func(name string, err error) Router {
+		return RouterFunc(func(req *Request) (string, interface{}, error) {
+			calls = append(calls, name)
+			return name, nil, err
+		})
+	}
+
+	name, _, err := Routers(router("a", ErrNoRoute), router("b", nil), router("c", nil)).Route(NewRequest("foo", nil))
+	if err != nil || name != "b" {
+		t.Errorf("expected b router to choose, got %s and %v", name, err)
+	}
+
+	if len(calls) != 2 {
+		t.Errorf("expected 2 routers to be asked, %d were", len(calls))
+	}
+
+	_, _, err = Routers(router("a", ErrNoRoute)).Route(NewRequest("foo", nil))
+	if err != ErrNoRoute {
+		t.Errorf("expected no route error, got %v", err)
+	}
+}
+
+func TestAnalyzerRoute(t *testing.T) {
+	analyzer := Analyzer(func(req *Request) (string, interface{}) {
+		return "", nil
+	})
+
+	if _, _, err := analyzer.Route(NewRequest("foo", nil)); err != ErrNoRoute {
+		t.Errorf("expected no route error, got %v", err)
+	}
+}
+
+func TestEngineRouter(t *testing.T) {
+	e := NewEngine()
+	e.SetPlugins(dummyPlugins())
+
+	e.SetRouter(RouterFunc(func(req *Request) (string, interface{}, error) {
+		return "", nil, ErrNoRoute
+	}))
+	if plugin, _, _ := e.Process(NewRequest("how are you?", nil)); plugin != "salute" {
+		t.Errorf("expected engine to fall back to scoring, %s plugin processed", plugin)
+	}
+
+	e.SetRouter(RouterFunc(func(req *Request) (string, interface{}, error) {
+		return "unknown", nil, nil
+	}))
+	if _, _, err := e.Process(NewRequest("how are you?", nil)); err == nil {
+		t.Errorf("expected error for unknown plugin")
+	} else if _, ok := err.(*UnknownPluginError); !ok {
+		t.Errorf("expected unknown plugin error, got %v", err)
+	}
+
+	routeErr := errors.New("route error")
+	e.SetRouter(RouterFunc(func(req *Request) (string, interface{}, error) {
+		return "", nil, routeErr
+	}))
+	if _, _, err := e.Process(NewRequest("how are you?", nil)); err != routeErr {
+		t.Errorf("expected route error, got %v", err)
+	}
+}
+
+func TestConfigRouter(t *testing.T) {
+	analyzer := Analyzer(func(req *Request) (string, interface{}) {
+		return "foo", nil
+	})
+
+	server := NewServer(Config{
+		Plugins:  dummyPlugins(),
+		Analyzer: analyzer,
+	})
+
+	plugin, _, _ := server.GetEngine().Process(NewRequest("how are you?", nil))
+	if plugin != "foo" {
+		t.Errorf("expected analyzer of the config to choose foo plugin, %s was chosen", plugin)
+	}
+}
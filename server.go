@@ -1,26 +1,48 @@
 package trevor
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"unicode/utf8"
 )
 
 // Server is a Trevor server ready to run
 type Server interface {
-	// Run starts the server.
+	// Run initializes the engine, schedules its pokes and serves Handler on the
+	// configured host and port. It returns as soon as the engine fails to start
+	// or the server stops listening.
 	Run() error
 
 	// GetEngine returns the current Engine being used on the server.
 	GetEngine() Engine
+
+	// Handler returns the handler with all the endpoints of the server, to be mounted on
+	// any router or used with httptest. Run serves it, but when the handler is used on
+	// its own the engine must be initialized with Init before serving requests.
+	Handler() http.Handler
+
+	// AdminHandler returns the handler of the administration endpoints, to be mounted
+	// separately from the server, e.g. on a port only reachable from the internal network.
+	// Handler mounts it under Config.AdminEndpoint if it is not empty.
+	AdminHandler() http.Handler
+
+	// Shutdown stops the server gracefully. It stops listening, waits for the
+	// requests being processed to finish and shuts down the engine.
+	Shutdown(context.Context) error
 }
 
 type server struct {
 	engine Engine
 	config Config
+
+	mu         sync.Mutex
+	httpServer *http.Server
 }
 
 func NewServer(config Config) Server {
@@ -28,6 +50,24 @@ func NewServer(config Config) Server {
 	engine.SetServices(config.Services)
 	engine.SetPlugins(config.Plugins)
 	engine.SetMiddleware(config.Middleware)
+	engine.SetRouter(configRouter(config))
+	engine.SetRanker(config.Ranker)
+	engine.SetCalibrator(config.Calibrator)
+	for name, calibrator := range config.Calibrators {
+		engine.SetPluginCalibrator(name, calibrator)
+	}
+	engine.SetTimeout(config.Timeout)
+	engine.SetPluginTimeout(config.PluginTimeout)
+	engine.SetConcurrency(config.Concurrency)
+	engine.SetShortCircuit(config.ShortCircuit)
+	engine.SetFallbackDepth(config.FallbackDepth)
+	engine.SetMinScore(config.MinScore)
+	engine.SetNoMatchPlugin(config.NoMatchPlugin)
+	engine.SetAlternatives(config.Alternatives)
+	engine.SetLanguageDetector(config.LanguageDetector)
+	engine.SetNormalizer(config.Normalizer)
+	engine.SetTokenizer(config.Tokenizer)
+	engine.SetExtractor(config.Extractor)
 
 	return &server{
 		engine: engine,
@@ -35,92 +75,174 @@ func NewServer(config Config) Server {
 	}
 }
 
+// configRouter returns the router defined in the config, if any.
+func configRouter(config Config) Router {
+	switch {
+	case config.Router != nil && config.Analyzer != nil:
+		return Routers(config.Router, config.Analyzer)
+	case config.Router != nil:
+		return config.Router
+	case config.Analyzer != nil:
+		return config.Analyzer
+	default:
+		return nil
+	}
+}
+
 func (s *server) GetEngine() Engine {
 	return s.engine
 }
 
-func (s *server) Run() error {
-	var (
-		endpoint   = "process"
-		inputName  = "text"
-		CORSOrigin = "*"
-	)
+// inputFields are the keys of the JSON object passed to the endpoint.
+type inputFields struct {
+	text    string
+	plugin  string
+	plugins string
+	locale  string
+}
 
-	if s.config.Endpoint != "" {
-		endpoint = s.config.Endpoint
+// NewHandler returns an http.Handler with the endpoints of a server using the given engine
+// and config, to be mounted on any router. The engine must be initialized before
+// serving requests and its pokes scheduled, if needed.
+func NewHandler(engine Engine, config Config) http.Handler {
+	return (&server{engine: engine, config: config}).Handler()
+}
+
+func (s *server) Handler() http.Handler {
+	config := s.config.withDefaults()
+	fields := inputFields{
+		text:    config.InputFieldName,
+		plugin:  config.PluginFieldName,
+		plugins: config.PluginsFieldName,
+		locale:  config.LocaleFieldName,
 	}
 
-	if s.config.InputFieldName != "" {
-		inputName = s.config.InputFieldName
+	router := http.NewServeMux()
+	router.HandleFunc("/"+config.Endpoint, processHandler(fields, config.CORSOrigin, s))
+	if config.AdminEndpoint != "" {
+		prefix := "/" + config.AdminEndpoint
+		router.Handle(prefix+"/", http.StripPrefix(prefix, s.AdminHandler()))
 	}
 
-	if s.config.CORSOrigin != "" {
-		CORSOrigin = s.config.CORSOrigin
+	return router
+}
+
+func (s *server) Run() error {
+	tlsConfig, err := s.config.tlsConfig()
+	if err != nil {
+		return err
 	}
 
-	router := http.NewServeMux()
-	router.HandleFunc("/"+endpoint, processHandler(inputName, endpoint, CORSOrigin, s))
+	if err := s.engine.Init(); err != nil {
+		return err
+	}
 
 	s.engine.SchedulePokes()
 
-	var err error
-	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
-	if !s.config.Secure {
-		err = http.ListenAndServe(addr, router)
+	httpServer := &http.Server{
+		Addr:      fmt.Sprintf("%s:%d", s.config.Host, s.config.Port),
+		Handler:   s.Handler(),
+		TLSConfig: tlsConfig,
+	}
+
+	s.mu.Lock()
+	s.httpServer = httpServer
+	s.mu.Unlock()
+
+	if tlsConfig == nil {
+		err = httpServer.ListenAndServe()
 	} else {
-		err = http.ListenAndServeTLS(addr, s.config.CertPerm, s.config.KeyPerm, router)
+		err = httpServer.ListenAndServeTLS("", "")
+	}
+
+	if err == http.ErrServerClosed {
+		return nil
 	}
 
 	return err
 }
 
-func processHandler(inputName, endpoint, CORSOrigin string, s *server) func(http.ResponseWriter, *http.Request) {
-	var errorText = inputName + " field is mandatory and can not be empty"
+func (s *server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	httpServer := s.httpServer
+	s.mu.Unlock()
+
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	return s.engine.Shutdown(ctx)
+}
 
+func processHandler(fields inputFields, CORSOrigin string, s *server) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "POST" {
 			var (
-				jsonInput map[string]string
-				response  map[string]interface{}
-				status    int
+				response map[string]interface{}
+				status   int
+				message  string
 			)
 
-			content, err := ioutil.ReadAll(r.Body)
-			if err = json.Unmarshal(content, &jsonInput); err == nil {
-				text, ok := jsonInput[inputName]
-				if ok && utf8.RuneCountInString(strings.TrimSpace(text)) > 0 {
-					req := NewRequest(strings.TrimSpace(text), r)
+			req, err := readRequest(r, fields, s.config.Schema)
+			if err != nil {
+				message = err.Error()
+			} else {
+				if s.engine.Memory() != nil {
+					req.Token = r.Header.Get(s.engine.Memory().TokenHeader())
+				}
+
+				dataType, data, err := s.engine.Process(req)
+				if err != nil {
+					if r.Context().Err() != nil {
+						// the client has gone away, there is no one to answer to
+						return
+					}
+
+					message = err.Error()
+					status = errorStatus(err)
+				} else {
 					if s.engine.Memory() != nil {
-						req.Token = r.Header.Get(s.engine.Memory().TokenHeader())
+						w.Header().Set(s.engine.Memory().TokenHeader(), req.Token)
+					}
+
+					response = map[string]interface{}{
+						"error": false,
+						"type":  dataType,
+						"data":  data,
+					}
+
+					if len(req.Failed) > 0 {
+						response["failed"] = failedResponse(req.Failed)
+					}
+
+					if s.config.Alternatives > 0 {
+						response["alternatives"] = req.Alternatives
 					}
 
-					dataType, data, err := s.engine.Process(req)
-					if err != nil {
-						errorText = err.Error()
-					} else {
-						if s.engine.Memory() != nil {
-							w.Header().Set(s.engine.Memory().TokenHeader(), req.Token)
-						}
-
-						response = map[string]interface{}{
-							"error": false,
-							"type":  dataType,
-							"data":  data,
-						}
-						status = http.StatusOK
+					if req.Locale != "" {
+						response["locale"] = req.Locale
 					}
+					status = http.StatusOK
 				}
 			}
 
-			if status == 0 {
+			if response == nil {
 				response = map[string]interface{}{
 					"error":   true,
-					"message": errorText,
+					"message": message,
+				}
+
+				if status == 0 {
+					status = http.StatusBadRequest
 				}
-				status = http.StatusBadRequest
 			}
 
 			w.Header().Set("Content-Type", "application/json")
+			if req != nil {
+				setContentLanguage(w, req)
+			}
 			addCORS(r, w, CORSOrigin)
 			w.WriteHeader(status)
 			resp, _ := json.Marshal(response)
@@ -134,6 +256,90 @@ func processHandler(inputName, endpoint, CORSOrigin string, s *server) func(http
 	}
 }
 
+// readRequest creates a new Request with the JSON object in the body of the given HTTP request.
+// The attributes of the request are validated against the given schema, if any.
+func readRequest(r *http.Request, fields inputFields, schema *Schema) (*Request, error) {
+	var (
+		jsonInput map[string]json.RawMessage
+		text      string
+		errorText = fields.text + " field is mandatory and can not be empty"
+	)
+
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, &jsonInput); err != nil {
+		return nil, errors.New(errorText)
+	}
+
+	if err := decodeField(jsonInput, fields.text, &text); err != nil || utf8.RuneCountInString(strings.TrimSpace(text)) == 0 {
+		return nil, errors.New(errorText)
+	}
+
+	req := NewRequest(strings.TrimSpace(text), r)
+	if err := decodeField(jsonInput, fields.plugin, &req.Plugin); err != nil {
+		return nil, fmt.Errorf("%s field must be a string", fields.plugin)
+	}
+
+	if err := decodeField(jsonInput, fields.plugins, &req.AllowedPlugins); err != nil {
+		return nil, fmt.Errorf("%s field must be a list of strings", fields.plugins)
+	}
+
+	if err := decodeField(jsonInput, fields.locale, &req.Locale); err != nil {
+		return nil, fmt.Errorf("%s field must be a string", fields.locale)
+	}
+
+	req.Attributes = Attributes{}
+	for name, value := range jsonInput {
+		if name != fields.text && name != fields.plugin && name != fields.plugins && name != fields.locale {
+			req.Attributes[name] = value
+		}
+	}
+
+	if schema != nil {
+		if err := schema.Validate(req.Attributes); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// decodeField decodes the value of the given field into v, if the field exists.
+func decodeField(input map[string]json.RawMessage, name string, v interface{}) error {
+	if raw, ok := input[name]; ok {
+		return json.Unmarshal(raw, v)
+	}
+
+	return nil
+}
+
+func errorStatus(err error) int {
+	var noMatch *NoMatchError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &noMatch):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func failedResponse(errs []PluginError) []map[string]interface{} {
+	failed := make([]map[string]interface{}, len(errs))
+	for i, err := range errs {
+		failed[i] = map[string]interface{}{
+			"type":    err.Plugin,
+			"message": err.Err.Error(),
+		}
+	}
+
+	return failed
+}
+
 func addCORS(r *http.Request, w http.ResponseWriter, origin string) {
 	w.Header().Set("Access-Control-Allow-Origin", origin)
 	w.Header().Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -75,6 +76,30 @@ func TestRunTextEmpty(t *testing.T) {
 	}
 }
 
+func TestRunInvalidPlugins(t *testing.T) {
+	body, status := makeRequest(`{"input":"foo","plugins":"salute"}`, 9102)
+
+	if status != "400 Bad Request" {
+		t.Errorf("expected status 400, got %s", status)
+	}
+
+	if !strings.Contains(body, "plugins field must be a list of strings") {
+		t.Errorf("unexpected error message: %s", body)
+	}
+}
+
+func TestRunAllowedPlugins(t *testing.T) {
+	body, status := makeRequest(`{"input":"how are you?","plugins":["foo"]}`, 9103)
+
+	if status != "400 Bad Request" {
+		t.Errorf("expected status 400, got %s", status)
+	}
+
+	if !strings.Contains(body, "i always throw error") {
+		t.Errorf("expected foo plugin to process, got %s", body)
+	}
+}
+
 func TestRunPluginError(t *testing.T) {
 	_, status := makeRequest(`{"input":"foo"}`, 9094)
 
@@ -83,6 +108,95 @@ func TestRunPluginError(t *testing.T) {
 	}
 }
 
+func TestRunFallback(t *testing.T) {
+	server := NewServer(Config{
+		Plugins:       dummyPlugins(),
+		Port:          9098,
+		FallbackDepth: 1,
+	})
+
+	go func() {
+		server.Run()
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+
+	resp, err := http.Post("http://0.0.0.0:9098/process", "application/json", strings.NewReader(`{"text":"foo"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	expected := `{"data":"fine, and you?","error":false,"failed":[{"message":"i always throw error","type":"foo"}],"type":"salute"}`
+	if strings.TrimSpace(string(body)) != expected {
+		t.Errorf("invalid response got: %s", body)
+	}
+}
+
+func TestRunNoMatch(t *testing.T) {
+	server := NewServer(Config{
+		Plugins:  dummyPlugins(),
+		Port:     9100,
+		MinScore: 6,
+	})
+
+	go func() {
+		server.Run()
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+
+	resp, err := http.Post("http://0.0.0.0:9100/process", "application/json", strings.NewReader(`{"text":"foo"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422, got %s", resp.Status)
+	}
+}
+
+func TestRunAlternatives(t *testing.T) {
+	server := NewServer(Config{
+		Plugins:      dummyPlugins(),
+		Port:         9101,
+		Alternatives: 2,
+	})
+
+	go func() {
+		server.Run()
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+
+	for _, c := range []struct {
+		input    string
+		expected string
+	}{
+		{
+			`{"text":"how are you?"}`,
+			`{"alternatives":[{"type":"foo","score":5,"exact_match":false}],"data":"fine, and you?","error":false,"type":"salute"}`,
+		},
+		{
+			`{"text":"hello","plugin":"salute"}`,
+			`{"alternatives":[],"data":"fine, and you?","error":false,"type":"salute"}`,
+		},
+	} {
+		resp, err := http.Post("http://0.0.0.0:9101/process", "application/json", strings.NewReader(c.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if strings.TrimSpace(string(body)) != c.expected {
+			t.Errorf("invalid response got: %s", body)
+		}
+	}
+}
+
 // This is just for code coverage
 func TestGetEngine(t *testing.T) {
 	server := NewServer(Config{
@@ -93,6 +207,57 @@ func TestGetEngine(t *testing.T) {
 	server.GetEngine()
 }
 
+func TestHandler(t *testing.T) {
+	server := NewServer(Config{
+		Plugins:       dummyPlugins(),
+		AdminEndpoint: "admin",
+	})
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/process", "application/json", strings.NewReader(`{"text":"how are you?"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	expected := `{"data":"fine, and you?","error":false,"type":"salute"}`
+	if string(body) != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+
+	resp, err = http.Get(ts.URL + "/admin/plugins")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected admin endpoints to be mounted, got status %d", resp.StatusCode)
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	engine := NewEngine()
+	engine.SetPlugins(dummyPlugins())
+
+	var wrapped bool
+	handler := NewHandler(engine, Config{Endpoint: "ask"})
+	router := http.NewServeMux()
+	router.Handle("/trevor/", http.StripPrefix("/trevor", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wrapped = true
+		handler.ServeHTTP(w, r)
+	})))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/trevor/ask", strings.NewReader(`{"text":"how are you?"}`)))
+
+	if rec.Code != http.StatusOK || !wrapped {
+		t.Errorf("expected request to be processed through the router, got status %d", rec.Code)
+	}
+}
+
 // Just for code coverage too
 func TestNotFound(t *testing.T) {
 	_, status := makeRequestWithMethod(`whatever`, 9097, "GET")
@@ -10,3 +10,14 @@ type Service interface {
 	// SetName sets the name to the service
 	SetName(string)
 }
+
+// InjectableService is a service that requests dependency injection of other services.
+type InjectableService interface {
+	Service
+
+	// NeededServices returns an array with the name of all needed services.
+	NeededServices() []string
+
+	// SetService injects a service to the service
+	SetService(string, Service)
+}
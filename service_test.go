@@ -0,0 +1,94 @@
+package trevor
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type dependentInjectableService struct {
+	name     string
+	needs    []string
+	injected map[string]Service
+	inits    *[]string
+}
+
+func (s *dependentInjectableService) Name() string {
+	return s.name
+}
+
+func (s *dependentInjectableService) SetName(name string) {
+	s.name = name
+}
+
+func (s *dependentInjectableService) NeededServices() []string {
+	return s.needs
+}
+
+func (s *dependentInjectableService) SetService(name string, service Service) {
+	if s.injected == nil {
+		s.injected = map[string]Service{}
+	}
+
+	s.injected[name] = service
+}
+
+func (s *dependentInjectableService) Init() error {
+	*s.inits = append(*s.inits, s.name)
+	return nil
+}
+
+func TestInjectableServices(t *testing.T) {
+	var inits []string
+	recommendation := &dependentInjectableService{name: "recommendation", needs: []string{"cache", "index"}, inits: &inits}
+	cache := &dependentInjectableService{name: "cache", needs: []string{"index"}, inits: &inits}
+	index := &dependentInjectableService{name: "index", inits: &inits}
+
+	e := NewEngine()
+	e.SetServices([]Service{recommendation, cache, index})
+
+	if err := e.Init(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if recommendation.injected["cache"] != cache || recommendation.injected["index"] != index {
+		t.Errorf("expected cache and index to be injected to recommendation service")
+	}
+
+	if cache.injected["index"] != index {
+		t.Errorf("expected index to be injected to cache service")
+	}
+
+	if strings.Join(inits, ",") != "index,cache,recommendation" {
+		t.Errorf("expected services to be initialized in dependency order, got %v", inits)
+	}
+}
+
+func TestInjectableServicesMissing(t *testing.T) {
+	e := NewEngine()
+	e.SetServices([]Service{&dependentInjectableService{name: "recommendation", needs: []string{"cache"}}})
+
+	var missing *MissingServiceError
+	if err := e.Validate(); !errors.As(err, &missing) || missing.Component != "service recommendation" {
+		t.Errorf("expected missing service error, got %v", err)
+	}
+}
+
+func TestInjectableServicesCycle(t *testing.T) {
+	e := NewEngine()
+	e.SetServices([]Service{
+		&dependentInjectableService{name: "a", needs: []string{"b"}},
+		&dependentInjectableService{name: "b", needs: []string{"c"}},
+		&dependentInjectableService{name: "c", needs: []string{"a"}},
+	})
+
+	var cycle *DependencyCycleError
+	if err := e.Validate(); !errors.As(err, &cycle) {
+		t.Fatalf("expected dependency cycle error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(cycle.Cycle, []string{"a", "b", "c", "a"}) {
+		t.Errorf("unexpected cycle: %v", cycle.Cycle)
+	}
+}
@@ -0,0 +1,277 @@
+package trevor
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// snapshot is a set of plugins and services with their services already injected.
+// The engine replaces its snapshot as a whole, so every request is processed with
+// the same plugins and services from start to end.
+type snapshot struct {
+	plugins     []Plugin
+	pluginMap   map[string]int
+	services    map[string]Service
+	serviceList []Service
+	memory      MemoryService
+
+	// injectionErrors are the errors of the services that rejected their dependencies.
+	injectionErrors []error
+
+	// kept are the components shared with the snapshot being replaced. They are
+	// still processing requests and already have their services, so they are not
+	// injected again.
+	kept []interface{}
+
+	// requests are the requests being processed with this snapshot.
+	requests sync.WaitGroup
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{
+		services:  map[string]Service{},
+		pluginMap: map[string]int{},
+	}
+}
+
+// clone returns a copy of the snapshot that can be modified without affecting
+// the requests being processed with it.
+func (s *snapshot) clone() *snapshot {
+	c := &snapshot{
+		plugins:         s.plugins,
+		pluginMap:       make(map[string]int, len(s.pluginMap)),
+		services:        make(map[string]Service, len(s.services)),
+		serviceList:     append([]Service(nil), s.serviceList...),
+		memory:          s.memory,
+		injectionErrors: s.injectionErrors,
+	}
+
+	for name, i := range s.pluginMap {
+		c.pluginMap[name] = i
+	}
+
+	for name, service := range s.services {
+		c.services[name] = service
+	}
+
+	return c
+}
+
+func (s *snapshot) setPlugins(plugins []Plugin) {
+	s.addPlugins(plugins)
+	s.injectServices()
+}
+
+// addPlugins replaces the plugins of the snapshot without injecting their services.
+func (s *snapshot) addPlugins(plugins []Plugin) {
+	SortPlugins(plugins)
+	s.plugins = plugins
+
+	s.pluginMap = make(map[string]int, len(s.plugins))
+	for i, p := range s.plugins {
+		s.pluginMap[p.Name()] = i
+	}
+}
+
+func (s *snapshot) setServices(services []Service) {
+	s.addServices(services)
+	s.injectServiceDependencies()
+	s.setMemoryService()
+}
+
+// addServices adds the services to the snapshot without injecting their dependencies.
+func (s *snapshot) addServices(services []Service) {
+	for _, service := range services {
+		s.serviceList = append(s.serviceList, service)
+		s.services[service.Name()] = service
+	}
+}
+
+// inject injects the services into all the plugins and services of the snapshot and
+// returns the errors of the services that rejected their dependencies, if any.
+func (s *snapshot) inject() error {
+	s.injectServiceDependencies()
+	s.setMemoryService()
+	s.injectServices()
+
+	if len(s.injectionErrors) > 0 {
+		return &ConfigError{Errors: s.injectionErrors}
+	}
+
+	return nil
+}
+
+func (s *snapshot) getPlugin(name string) (Plugin, error) {
+	i, ok := s.pluginMap[name]
+	if !ok {
+		return nil, &UnknownPluginError{Name: name}
+	}
+
+	return s.plugins[i], nil
+}
+
+func (s *snapshot) getService(name string) Service {
+	return s.services[name]
+}
+
+// injectServiceDependencies injects to every service the services it depends on,
+// following the dependency order. Missing services are reported by Validate.
+func (s *snapshot) injectServiceDependencies() {
+	s.injectionErrors = nil
+	for _, service := range s.orderedServices() {
+		if containsComponent(s.kept, service) {
+			continue
+		}
+
+		for _, name := range serviceDependencies(service) {
+			dependency, ok := s.services[name]
+			if !ok {
+				continue
+			}
+
+			if err := injectService(service, name, dependency); err != nil {
+				s.injectionErrors = append(s.injectionErrors, fmt.Errorf("can't inject service %s to %s: %w", name, componentName(service), err))
+			}
+		}
+
+		s.injectFields(service)
+	}
+}
+
+func (s *snapshot) setMemoryService() {
+	s.memory = nil
+	if service, ok := s.services["memory"]; ok {
+		if memoryService, isMemoryService := service.(MemoryService); isMemoryService && service.Name() == "memory" {
+			if len(s.missingServices(componentName(service), serviceDependencies(service))) == 0 {
+				s.memory = memoryService
+			}
+		}
+	}
+}
+
+func (s *snapshot) injectServices() {
+	for _, plugin := range s.plugins {
+		if containsComponent(s.kept, plugin) {
+			continue
+		}
+
+		if injectablePlugin, ok := plugin.(InjectablePlugin); ok {
+			for _, serviceName := range injectablePlugin.NeededServices() {
+				// missing services are reported by Validate
+				if service, ok := s.services[serviceName]; ok {
+					injectablePlugin.SetService(serviceName, service)
+				}
+			}
+		}
+
+		s.injectFields(plugin)
+	}
+}
+
+// allowedPlugins returns the plugins the client allows to process the request.
+func (s *snapshot) allowedPlugins(req *Request) ([]Plugin, error) {
+	if len(req.AllowedPlugins) == 0 {
+		return s.plugins, nil
+	}
+
+	for _, name := range req.AllowedPlugins {
+		if _, ok := s.pluginMap[name]; !ok {
+			return nil, &UnknownPluginError{Name: name}
+		}
+	}
+
+	plugins := make([]Plugin, 0, len(req.AllowedPlugins))
+	for _, plugin := range s.plugins {
+		if isAllowed(req, plugin.Name()) {
+			plugins = append(plugins, plugin)
+		}
+	}
+
+	return plugins, nil
+}
+
+// orderedServices returns the services of the snapshot in dependency order, that
+// is, every service comes after the services it depends on.
+func (s *snapshot) orderedServices() []Service {
+	var (
+		ordered = make([]Service, 0, len(s.services))
+		visited = map[string]bool{}
+		visit   func(string)
+	)
+
+	visit = func(name string) {
+		service, ok := s.services[name]
+		if !ok || visited[name] {
+			return
+		}
+
+		visited[name] = true
+		for _, dep := range serviceDependencies(service) {
+			visit(dep)
+		}
+
+		ordered = append(ordered, service)
+	}
+
+	for _, service := range s.serviceList {
+		visit(service.Name())
+	}
+
+	return ordered
+}
+
+// initOrder returns all plugins and services in the order they have to be
+// initialized: services in dependency order and then plugins.
+func (s *snapshot) initOrder() []interface{} {
+	components := make([]interface{}, 0, len(s.plugins)+len(s.services))
+	for _, service := range s.orderedServices() {
+		components = append(components, service)
+	}
+
+	for _, plugin := range s.plugins {
+		components = append(components, plugin)
+	}
+
+	return components
+}
+
+// components returns all plugins and services in the order they have to be
+// shut down, which is the reverse of the order they are initialized.
+func (s *snapshot) components() []interface{} {
+	components := s.initOrder()
+	for i, j := 0, len(components)-1; i < j; i, j = i+1, j-1 {
+		components[i], components[j] = components[j], components[i]
+	}
+
+	return components
+}
+
+// exclude returns the given components that are not part of the snapshot, keeping their order.
+func (s *snapshot) exclude(components []interface{}) []interface{} {
+	current := s.initOrder()
+	result := make([]interface{}, 0, len(components))
+	for _, c := range components {
+		if !containsComponent(current, c) {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+func containsComponent(components []interface{}, c interface{}) bool {
+	for _, other := range components {
+		if sameComponent(other, c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameComponent reports whether a and b are the same plugin or service instance.
+func sameComponent(a, b interface{}) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
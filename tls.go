@@ -0,0 +1,139 @@
+package trevor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNoCertificate is returned when the server has to be run over TLS but there is no
+// certificate in the TLSConfig, nor in CertPerm and KeyPerm.
+var ErrNoCertificate = errors.New("TLS is enabled but there is no certificate: set CertPerm and KeyPerm or the certificates of TLSConfig")
+
+// CertificateReloader loads a certificate and its key from disk and loads them
+// again whenever any of the files is modified, so certificates can be renewed
+// without restarting the server.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+// NewCertificateReloader creates a new CertificateReloader with the given
+// PEM encoded certificate and key files.
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the certificate, loading it again first if the files
+// have been modified since the last time. If the new files can't be loaded,
+// e.g. because they are still being written, the previous certificate is returned.
+// It is meant to be used as the GetCertificate function of a tls.Config.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if modified, err := r.lastModified(); err == nil {
+		r.mu.RLock()
+		changed := modified.After(r.modified)
+		r.mu.RUnlock()
+
+		if changed {
+			r.reload()
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+func (r *CertificateReloader) reload() error {
+	modified, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.modified = modified
+	return nil
+}
+
+// lastModified returns the last time any of the files was modified.
+func (r *CertificateReloader) lastModified() (time.Time, error) {
+	var modified time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+
+	return modified, nil
+}
+
+// tlsConfig returns the TLS configuration the server has to be run with, or nil if
+// it has to be run over plain HTTP. Certificates in CertPerm and KeyPerm are reloaded
+// when they change unless the TLSConfig already provides the certificates.
+func (c Config) tlsConfig() (*tls.Config, error) {
+	if !c.Secure && c.TLSConfig == nil {
+		return nil, nil
+	}
+
+	config := &tls.Config{}
+	if c.TLSConfig != nil {
+		config = c.TLSConfig.Clone()
+	}
+
+	if len(config.Certificates) > 0 || config.GetCertificate != nil || config.GetConfigForClient != nil {
+		return config, nil
+	}
+
+	if c.CertPerm == "" || c.KeyPerm == "" {
+		return nil, ErrNoCertificate
+	}
+
+	reloader, err := NewCertificateReloader(c.CertPerm, c.KeyPerm)
+	if err != nil {
+		return nil, err
+	}
+
+	config.GetCertificate = reloader.GetCertificate
+	return config, nil
+}
+
+// clientCertificate returns the verified certificate of the client of the given
+// HTTP request, if any.
+func clientCertificate(r *http.Request) *x509.Certificate {
+	if r == nil || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return r.TLS.VerifiedChains[0][0]
+}
@@ -0,0 +1,215 @@
+package trevor
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return cert
+}
+
+// newTestCert creates a certificate for the given common name signed by the given
+// parent, or a self-signed CA if parent is nil.
+func newTestCert(t *testing.T, name string, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeTestCert(t *testing.T, cert *testCert, certFile, keyFile string, modified time.Time) {
+	if err := ioutil.WriteFile(certFile, cert.certPEM, 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := ioutil.WriteFile(keyFile, cert.keyPEM, 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	os.Chtimes(certFile, modified, modified)
+	os.Chtimes(keyFile, modified, modified)
+}
+
+func TestCertificateReloader(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile  = filepath.Join(dir, "key.pem")
+		first    = newTestCert(t, "first", 1, nil)
+		second   = newTestCert(t, "second", 2, nil)
+		now      = time.Now()
+	)
+
+	writeTestCert(t, first, certFile, keyFile, now)
+	reloader, err := NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cert, _ := reloader.GetCertificate(nil)
+	if !bytes.Equal(cert.Certificate[0], first.cert.Raw) {
+		t.Errorf("expected first certificate")
+	}
+
+	writeTestCert(t, second, certFile, keyFile, now.Add(time.Second))
+	cert, _ = reloader.GetCertificate(nil)
+	if !bytes.Equal(cert.Certificate[0], second.cert.Raw) {
+		t.Errorf("expected certificate to be reloaded")
+	}
+
+	ioutil.WriteFile(keyFile, []byte("not a key"), 0600)
+	os.Chtimes(keyFile, now.Add(2*time.Second), now.Add(2*time.Second))
+	cert, _ = reloader.GetCertificate(nil)
+	if !bytes.Equal(cert.Certificate[0], second.cert.Raw) {
+		t.Errorf("expected previous certificate to be kept if the new one is invalid")
+	}
+}
+
+func TestNewCertificateReloaderMissingFiles(t *testing.T) {
+	if _, err := NewCertificateReloader("missing.pem", "missing.key"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestRunWithoutCertificate(t *testing.T) {
+	cases := []Config{
+		{Plugins: dummyPlugins(), Secure: true},
+		{Plugins: dummyPlugins(), Secure: true, CertPerm: "cert.pem"},
+		{Plugins: dummyPlugins(), TLSConfig: &tls.Config{}},
+	}
+
+	for _, config := range cases {
+		if err := NewServer(config).Run(); err != ErrNoCertificate {
+			t.Errorf("expected ErrNoCertificate, got %v", err)
+		}
+	}
+}
+
+type clientCertPlugin struct{}
+
+func (p *clientCertPlugin) Analyze(req *Request) (Score, interface{}) {
+	return NewScore(10, true), nil
+}
+
+func (p *clientCertPlugin) Process(req *Request, _ interface{}) (interface{}, error) {
+	if req.ClientCertificate == nil {
+		return "anonymous", nil
+	}
+
+	return req.ClientCertificate.Subject.CommonName, nil
+}
+
+func (p *clientCertPlugin) Name() string {
+	return "client_cert"
+}
+
+func (p *clientCertPlugin) Precedence() int {
+	return 1
+}
+
+func TestRunMutualTLS(t *testing.T) {
+	var (
+		ca     = newTestCert(t, "ca", 1, nil)
+		server = newTestCert(t, "server", 2, ca)
+		client = newTestCert(t, "internal-caller", 3, ca)
+		pool   = x509.NewCertPool()
+	)
+	pool.AddCert(ca.cert)
+
+	s := NewServer(Config{
+		Plugins: []Plugin{&clientCertPlugin{}},
+		Port:    9108,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{server.tlsCertificate(t)},
+			MinVersion:   tls.VersionTLS12,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pool,
+		},
+	})
+	go s.Run()
+	defer s.Shutdown(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{client.tlsCertificate(t)},
+	}}}
+
+	resp, err := httpClient.Post("https://127.0.0.1:9108/process", "application/json", strings.NewReader(`{"text":"who am i"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !strings.Contains(string(body), `"data":"internal-caller"`) {
+		t.Errorf("expected client identity in the response, got %s", body)
+	}
+
+	httpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	if _, err := httpClient.Post("https://127.0.0.1:9108/process", "application/json", strings.NewReader(`{"text":"who am i"}`)); err == nil {
+		t.Errorf("expected clients without certificate to be rejected")
+	}
+}
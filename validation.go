@@ -0,0 +1,256 @@
+package trevor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConfigError contains all the problems found validating the configuration of an engine.
+type ConfigError struct {
+	// Errors are the problems found.
+	Errors []error
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the problems found.
+func (e *ConfigError) Unwrap() []error {
+	return e.Errors
+}
+
+// MissingServiceError is the problem of a component needing a service that does not exist.
+type MissingServiceError struct {
+	// Component is the plugin or service that needs the service.
+	Component string
+
+	// Service is the name of the missing service.
+	Service string
+}
+
+func (e *MissingServiceError) Error() string {
+	return fmt.Sprintf("service %s not found but is required by %s", e.Service, e.Component)
+}
+
+// DuplicateNameError is the problem of several plugins or several services having the same name.
+type DuplicateNameError struct {
+	// Kind is either "plugin" or "service".
+	Kind string
+
+	// Name is the duplicated name.
+	Name string
+}
+
+func (e *DuplicateNameError) Error() string {
+	return fmt.Sprintf("there is more than one %s with name %s", e.Kind, e.Name)
+}
+
+// DependencyCycleError is the problem of services depending on each other.
+type DependencyCycleError struct {
+	// Cycle are the names of the services in the cycle, starting and ending with the same service.
+	Cycle []string
+}
+
+func (e *DependencyCycleError) Error() string {
+	return "dependency cycle between services: " + strings.Join(e.Cycle, " -> ")
+}
+
+// ChangedDependencyError is the problem of reloading a plugin or service instance that
+// is still in use with a different instance of a service it depends on.
+type ChangedDependencyError struct {
+	// Component is the plugin or service kept from the previous configuration.
+	Component string
+
+	// Service is the name of the service that changed.
+	Service string
+}
+
+func (e *ChangedDependencyError) Error() string {
+	return fmt.Sprintf("service %s changed but %s is still in use, use a new instance of it instead", e.Service, e.Component)
+}
+
+func (e *engine) Validate() error {
+	return e.validate(e.current())
+}
+
+// validate checks the given snapshot can be used by the engine.
+func (e *engine) validate(s *snapshot) error {
+	var errs []error
+	errs = append(errs, duplicateNames("plugin", pluginNames(s.plugins))...)
+	errs = append(errs, duplicateNames("service", serviceNames(s.serviceList))...)
+
+	for _, plugin := range s.plugins {
+		errs = append(errs, s.missingServices(componentName(plugin), pluginDependencies(plugin))...)
+	}
+
+	for _, service := range s.orderedServices() {
+		errs = append(errs, s.missingServices(componentName(service), serviceDependencies(service))...)
+	}
+
+	errs = append(errs, s.injectionErrors...)
+	for _, service := range s.orderedServices() {
+		errs = append(errs, s.fieldErrors(service)...)
+	}
+
+	for _, plugin := range s.plugins {
+		errs = append(errs, s.fieldErrors(plugin)...)
+	}
+
+	errs = append(errs, s.dependencyCycles()...)
+
+	if e.noMatchPlugin != "" {
+		if _, ok := s.pluginMap[e.noMatchPlugin]; !ok {
+			errs = append(errs, &UnknownPluginError{Name: e.noMatchPlugin})
+		}
+	}
+
+	if e.languageDetector != "" {
+		if service, ok := s.services[e.languageDetector]; !ok {
+			errs = append(errs, &MissingServiceError{Component: "language detection", Service: e.languageDetector})
+		} else if _, ok := service.(LanguageDetector); !ok {
+			errs = append(errs, &ServiceTypeError{
+				Name:     e.languageDetector,
+				Expected: "trevor.LanguageDetector",
+				Actual:   fmt.Sprintf("%T", service),
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ConfigError{Errors: errs}
+	}
+
+	return nil
+}
+
+// changedDependencies returns an error for every component shared with the given
+// snapshot whose dependencies are not the same service instances in both of them.
+func (s *snapshot) changedDependencies(prev *snapshot) []error {
+	var (
+		errs   []error
+		shared = prev.initOrder()
+	)
+
+	for _, c := range s.initOrder() {
+		if !containsComponent(shared, c) {
+			continue
+		}
+
+		var deps []string
+		switch c := c.(type) {
+		case Plugin:
+			deps = pluginDependencies(c)
+		case Service:
+			deps = serviceDependencies(c)
+		}
+
+		for _, name := range deps {
+			if !sameComponent(s.services[name], prev.services[name]) {
+				errs = append(errs, &ChangedDependencyError{Component: componentName(c), Service: name})
+			}
+		}
+	}
+
+	return errs
+}
+
+func (s *snapshot) missingServices(component string, needed []string) []error {
+	var errs []error
+	for _, name := range needed {
+		if _, ok := s.services[name]; !ok {
+			errs = append(errs, &MissingServiceError{Component: component, Service: name})
+		}
+	}
+
+	return errs
+}
+
+// dependencyCycles returns an error for every cycle found in the dependencies between services.
+func (s *snapshot) dependencyCycles() []error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	var (
+		errs  []error
+		state = map[string]int{}
+		path  []string
+		visit func(string)
+	)
+
+	visit = func(name string) {
+		service, ok := s.services[name]
+		if !ok {
+			return
+		}
+
+		switch state[name] {
+		case visited:
+			return
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					cycle := append(append([]string{}, path[i:]...), name)
+					errs = append(errs, &DependencyCycleError{Cycle: cycle})
+					break
+				}
+			}
+			return
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range serviceDependencies(service) {
+			visit(dep)
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+
+	for _, service := range s.serviceList {
+		visit(service.Name())
+	}
+
+	return errs
+}
+
+func duplicateNames(kind string, names []string) []error {
+	var (
+		errs  []error
+		count = map[string]int{}
+	)
+
+	for _, name := range names {
+		count[name]++
+		if count[name] == 2 {
+			errs = append(errs, &DuplicateNameError{Kind: kind, Name: name})
+		}
+	}
+
+	return errs
+}
+
+func pluginNames(plugins []Plugin) []string {
+	names := make([]string, len(plugins))
+	for i, p := range plugins {
+		names[i] = p.Name()
+	}
+
+	return names
+}
+
+func serviceNames(services []Service) []string {
+	names := make([]string, len(services))
+	for i, s := range services {
+		names[i] = s.Name()
+	}
+
+	return names
+}
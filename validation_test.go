@@ -0,0 +1,73 @@
+package trevor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type selfStoredMemoryService struct {
+	memoryService
+}
+
+func (s *selfStoredMemoryService) NeededStore() string {
+	return "memory"
+}
+
+func (s *selfStoredMemoryService) SetStore(Service) error {
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	e := NewEngine()
+	e.SetServices([]Service{&fooService{}, &fooService{}})
+	e.SetPlugins([]Plugin{&fooPlugin{}, &fooPlugin{}, &barPlugin{}, &salutePlugin{}})
+	e.SetNoMatchPlugin("unknown")
+
+	err := e.Validate()
+	configErr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("expected config error, got %v", err)
+	}
+
+	expected := []error{
+		&DuplicateNameError{Kind: "plugin", Name: "foo"},
+		&DuplicateNameError{Kind: "service", Name: "foo"},
+		&MissingServiceError{Component: "plugin bar", Service: "bar"},
+		&UnknownPluginError{Name: "unknown"},
+	}
+
+	if !reflect.DeepEqual(configErr.Errors, expected) {
+		t.Errorf("expected errors %v, got %v", expected, configErr.Errors)
+	}
+
+	var missing *MissingServiceError
+	if !errors.As(err, &missing) {
+		t.Errorf("expected to find missing service error in config error")
+	}
+}
+
+func TestValidateCycle(t *testing.T) {
+	e := NewEngine()
+	e.SetServices([]Service{&selfStoredMemoryService{}})
+
+	err := e.Validate()
+	var cycle *DependencyCycleError
+	if !errors.As(err, &cycle) {
+		t.Fatalf("expected dependency cycle error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(cycle.Cycle, []string{"memory", "memory"}) {
+		t.Errorf("unexpected cycle: %v", cycle.Cycle)
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	e := NewEngine()
+	e.SetServices([]Service{&memoryService{}, &storeService{}, &barService{}})
+	e.SetPlugins([]Plugin{&rememberPlugin{}, &barPlugin{}})
+
+	if err := e.Validate(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}